@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/goat-systems/tzpay/v2/internal/config"
+	"github.com/goat-systems/tzpay/v2/internal/payout"
+	"github.com/goat-systems/tzpay/v2/internal/rpc"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+const defaultDryRunGaslimit = 10600
+
+// DryrunCommand returns a cobra command that simulates a cycle's payout
+// without injecting anything, so operators can vet it before the server
+// gets to it.
+func DryrunCommand() *cobra.Command {
+	var gaslimit int
+
+	var dryrun = &cobra.Command{
+		Use:     "dryrun <cycle>",
+		Short:   "dryrun simulates a cycle's payout and prints estimated fees without injecting",
+		Example: `tzpay dryrun 328`,
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			cycle, err := strconv.Atoi(args[0])
+			if err != nil {
+				log.WithField("error", err.Error()).Fatal("cycle must be an integer.")
+			}
+
+			if err := runDryrun(cycle, gaslimit); err != nil {
+				log.WithField("error", err.Error()).Fatal("Dry-run failed.")
+			}
+		},
+	}
+
+	dryrun.Flags().IntVar(&gaslimit, "gaslimit", defaultDryRunGaslimit, "gas limit used for the simulated transfers.")
+	return dryrun
+}
+
+func runDryrun(cycle int, gaslimit int) error {
+	cfg, err := config.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to load configuration")
+	}
+
+	client, err := rpc.New(cfg.API.Tezos)
+	if err != nil {
+		return errors.Wrap(err, "failed to connect to tezos rpc endpoints")
+	}
+
+	p, err := payout.New(cfg, cycle, false, false)
+	if err != nil {
+		return errors.Wrap(err, "failed to compute payout for cycle")
+	}
+
+	result, err := payout.SimulateBatchPayment(client, cfg.Wallet.Address, p.Payments(), gaslimit)
+	if err != nil {
+		return errors.Wrap(err, "failed to simulate batch payment")
+	}
+
+	printSimulationResult(cycle, result)
+	return nil
+}
+
+func printSimulationResult(cycle int, result payout.SimulationResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "Dry-run for cycle %d (nothing injected)\n\n", cycle)
+	fmt.Fprintln(w, "DESTINATION\tAMOUNT\tEST. FEE\tCONSUMED GAS\tERRORS")
+
+	for _, batch := range result.Batches {
+		for _, estimated := range batch {
+			errs := "-"
+			if len(estimated.Errors) > 0 {
+				errs = fmt.Sprintf("%v", estimated.Errors)
+			}
+			fmt.Fprintf(w, "%s\t%.0f\t%d\t%d\t%s\n",
+				estimated.Payment.Address, estimated.Payment.Amount, estimated.Fee, estimated.ConsumedGas, errs)
+		}
+	}
+
+	w.Flush()
+}
@@ -1,11 +1,13 @@
 package cmd
 
 import (
+	"path/filepath"
 	"time"
 
-	"github.com/goat-systems/go-tezos/v3/rpc"
 	"github.com/goat-systems/tzpay/v2/internal/config"
 	"github.com/goat-systems/tzpay/v2/internal/payout"
+	"github.com/goat-systems/tzpay/v2/internal/payout/tracker"
+	"github.com/goat-systems/tzpay/v2/internal/rpc"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -16,6 +18,7 @@ type server struct {
 	rpcClient rpc.IFace
 	cfg       config.Config
 	runner    Run
+	tracker   *tracker.OperationTracker
 }
 
 func newServer(verbose bool) (server, error) {
@@ -26,11 +29,36 @@ func newServer(verbose bool) (server, error) {
 
 	rpc, err := rpc.New(config.API.Tezos)
 	if err != nil {
-		return server{}, errors.Wrap(err, "failed to connect to tezos rpc")
+		return server{}, errors.Wrap(err, "failed to connect to tezos rpc endpoints")
 	}
 
 	runner := NewRun(false, verbose)
-	queue := payout.NewQueue(runner.notifier)
+
+	signer, err := payout.NewSigner(config.Signer, payout.WalletCredentials{
+		Address:         config.Wallet.Address,
+		Public:          config.Wallet.Public,
+		Secret:          config.Wallet.Secret,
+		EncryptedSecret: config.Wallet.EncryptedSecret,
+		Password:        config.Wallet.Password,
+	})
+	if err != nil {
+		return server{}, errors.Wrap(err, "failed to construct signer")
+	}
+
+	queueStore, err := payout.NewBoltQueueStore(filepath.Join(config.DataDir, "queue.db"))
+	if err != nil {
+		return server{}, errors.Wrap(err, "failed to open queue store")
+	}
+
+	opStore, err := tracker.NewBoltStore(filepath.Join(config.DataDir, "tracker.db"))
+	if err != nil {
+		return server{}, errors.Wrap(err, "failed to open operation tracker store")
+	}
+
+	opTracker := tracker.New(rpc, signer, opStore, queueStore, runner.notifier, tracker.Config{})
+	opTracker.Start()
+
+	queue := payout.NewQueue(runner.notifier, queueStore, rpc, signer, config.Payment.Fee, config.Payment.GasLimit, config.Payment.Concurrency, opTracker)
 	queue.Start()
 
 	return server{
@@ -38,6 +66,7 @@ func newServer(verbose bool) (server, error) {
 		rpcClient: rpc,
 		cfg:       config,
 		runner:    runner,
+		tracker:   opTracker,
 	}, nil
 }
 
@@ -71,8 +100,17 @@ func (s *server) start() {
 		log.WithField("error", err.Error()).Error("Server failed to get current cycle.")
 	}
 
+	currentCycle := block.Metadata.Level.Cycle
+	if lastConfirmed, ok, err := s.queue.Rehydrate(s.cfg); err != nil {
+		log.WithField("error", err.Error()).Error("Server failed to rehydrate queue from disk.")
+	} else if ok {
+		// Trust the queue's own record of what it last confirmed over the
+		// chain's current cycle, so a restart never skips a cycle that was
+		// still in flight when tzpay last stopped.
+		currentCycle = lastConfirmed + 1
+	}
+
 	go func() {
-		currentCycle := block.Metadata.Level.Cycle
 		log.Infof("Current cycle: %d.", currentCycle)
 		ticker := time.NewTicker(time.Minute)
 		for range ticker.C {
@@ -82,17 +120,33 @@ func (s *server) start() {
 			}
 
 			if currentCycle < block.Metadata.Level.Cycle {
-				payout, err := payout.New(s.runner.config, currentCycle, true, s.runner.verbose)
+				pending, err := s.tracker.Pending()
 				if err != nil {
-					log.WithField("error", err.Error()).Fatal("Failed to intialize payout.")
+					log.WithField("error", err.Error()).Error("Server failed to check operation tracker.")
+					continue
 				}
-				log.Infof("Adding payout for for cycle to queue: %d.", currentCycle)
-				s.queue.Enqueue(*payout)
-				log.Infof("New current cycle: %d.", block.Metadata.Level.Cycle)
-				currentCycle = block.Metadata.Level.Cycle
+				if pending {
+					log.Infof("Cycle %d has unconfirmed operations, holding the queue until they confirm.", currentCycle)
+					continue
+				}
+
+				// Enqueue every cycle between currentCycle and the new head
+				// cycle, not just currentCycle itself: if serv was down (or
+				// held back by a pending tracker check) across more than one
+				// cycle boundary, jumping straight to block.Metadata.Level.Cycle
+				// would silently skip paying the cycles in between.
+				for ; currentCycle < block.Metadata.Level.Cycle; currentCycle++ {
+					payout, err := payout.New(s.runner.config, currentCycle, true, s.runner.verbose)
+					if err != nil {
+						log.WithField("error", err.Error()).Fatal("Failed to intialize payout.")
+					}
+					log.Infof("Adding payout for for cycle to queue: %d.", currentCycle)
+					s.queue.Enqueue(*payout)
+				}
+				log.Infof("New current cycle: %d.", currentCycle)
 			}
 		}
 	}()
 
 	<-quit
-}
\ No newline at end of file
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/goat-systems/tzpay/v2/internal/config"
+	"github.com/goat-systems/tzpay/v2/internal/payout"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// StatusCommand returns a cobra command that reads the persisted payout
+// queue and prints each cycle's progress, so an operator can tell what
+// serv did across a restart without tailing logs.
+func StatusCommand() *cobra.Command {
+	var status = &cobra.Command{
+		Use:     "status",
+		Short:   "status prints the persisted payout queue's per-cycle progress",
+		Example: `tzpay status`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := runStatus(); err != nil {
+				log.WithField("error", err.Error()).Fatal("Failed to read status.")
+			}
+		},
+	}
+
+	return status
+}
+
+func runStatus() error {
+	cfg, err := config.New()
+	if err != nil {
+		return errors.Wrap(err, "failed to load configuration")
+	}
+
+	store, err := payout.NewBoltQueueStoreReadOnly(filepath.Join(cfg.DataDir, "queue.db"))
+	if err != nil {
+		return errors.Wrap(err, "failed to open queue store")
+	}
+	defer store.Close()
+
+	records, err := store.All()
+	if err != nil {
+		return errors.Wrap(err, "failed to read queue records")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "CYCLE\tSTATE\tOPHASHES\tERROR")
+	for _, record := range records {
+		errMsg := record.Error
+		if errMsg == "" {
+			errMsg = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%v\t%s\n", record.Cycle, record.State, record.Ophashes, errMsg)
+	}
+	w.Flush()
+
+	return nil
+}
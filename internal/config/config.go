@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// SignerBackend identifies which implementation of payout.Signer the baker
+// wants to sign operations with.
+type SignerBackend string
+
+const (
+	// SignerBackendLocal signs in-process with a libsodium keypair loaded from
+	// an edsk secret. This is the default and matches tzpay's original behavior.
+	SignerBackendLocal SignerBackend = "local"
+	// SignerBackendRemote delegates signing to a tezos-signer compatible HTTP
+	// daemon, keeping the secret key off the box running tzpay.
+	SignerBackendRemote SignerBackend = "remote"
+	// SignerBackendLedger delegates signing to a Ledger hardware wallet
+	// running the Tezos app, over USB HID.
+	SignerBackendLedger SignerBackend = "ledger"
+)
+
+// RemoteSignerConfig holds the connection details for a tezos-signer backend.
+type RemoteSignerConfig struct {
+	URL         string `mapstructure:"url"`
+	BearerToken string `mapstructure:"bearer_token"`
+	TLSCert     string `mapstructure:"tls_cert"`
+	TLSKey      string `mapstructure:"tls_key"`
+	TLSCACert   string `mapstructure:"tls_ca_cert"`
+}
+
+// LedgerSignerConfig holds the connection details for a Ledger hardware backend.
+type LedgerSignerConfig struct {
+	DevicePath string `mapstructure:"device_path"`
+	// DerivationPath is the BIP32 path used to derive the baker's key on the device.
+	DerivationPath string `mapstructure:"derivation_path"`
+}
+
+// Signer groups the configuration needed to construct a payout.Signer,
+// regardless of which backend is selected.
+type Signer struct {
+	Backend SignerBackend      `mapstructure:"backend"`
+	Remote  RemoteSignerConfig `mapstructure:"remote"`
+	Ledger  LedgerSignerConfig `mapstructure:"ledger"`
+}
+
+// API holds the addresses of external services tzpay talks to.
+type API struct {
+	// Tezos is the pool of RPC endpoints tzpay round-robins reads across and
+	// broadcasts injections to. A single entry is equivalent to the old
+	// single-endpoint behavior.
+	Tezos []string `mapstructure:"tezos"`
+}
+
+// Wallet identifies the baker's address and, for the local signer backend,
+// the key material used to reconstruct it. Secret (a plaintext edsk) and
+// EncryptedSecret (a password-protected edesk, decrypted with Password) are
+// mutually exclusive ways to supply the same key.
+type Wallet struct {
+	Address         string `mapstructure:"address"`
+	Public          string `mapstructure:"public"`
+	Secret          string `mapstructure:"secret"`
+	EncryptedSecret string `mapstructure:"encrypted_secret"`
+	Password        string `mapstructure:"password"`
+}
+
+// Payment holds the fee and gas limit Queue falls back to when it can't
+// simulate a cycle to estimate them automatically (see
+// Queue.estimateFeeAndGas); in the common case these are overridden per
+// cycle by the simulation result and only matter as a safety net.
+type Payment struct {
+	Fee      int `mapstructure:"fee"`
+	GasLimit int `mapstructure:"gas_limit"`
+	// Concurrency bounds how many batches CreateBatchPayment forges, signs,
+	// and injects at once, and how many cycles the serv queue will run
+	// through that pipeline at once.
+	Concurrency int `mapstructure:"concurrency"`
+}
+
+// Config is tzpay's top level configuration, loaded from $HOME/.tzpay.yaml
+// (or the file pointed to by TZPAY_CONFIG) and environment overrides.
+type Config struct {
+	API     API     `mapstructure:"api"`
+	Wallet  Wallet  `mapstructure:"wallet"`
+	Signer  Signer  `mapstructure:"signer"`
+	Payment Payment `mapstructure:"payment"`
+	// DataDir is where tzpay keeps the operation tracker's and payout
+	// queue's on-disk state.
+	DataDir string `mapstructure:"data_dir"`
+}
+
+// New loads Config from disk and the environment.
+func New() (Config, error) {
+	var config Config
+
+	v := viper.New()
+	v.SetConfigName(".tzpay")
+	v.SetConfigType("yaml")
+	v.AddConfigPath("$HOME")
+	v.AddConfigPath(".")
+	if path := os.Getenv("TZPAY_CONFIG"); path != "" {
+		v.SetConfigFile(path)
+	}
+
+	v.SetEnvPrefix("tzpay")
+	v.AutomaticEnv()
+
+	v.SetDefault("signer.backend", string(SignerBackendLocal))
+	v.SetDefault("data_dir", "$HOME/.tzpay")
+	v.SetDefault("payment.concurrency", 4)
+
+	if err := v.ReadInConfig(); err != nil {
+		return config, errors.Wrap(err, "failed to read tzpay config")
+	}
+
+	if err := v.Unmarshal(&config); err != nil {
+		return config, errors.Wrap(err, "failed to unmarshal tzpay config")
+	}
+
+	if err := config.Signer.validate(); err != nil {
+		return config, errors.Wrap(err, "invalid signer configuration")
+	}
+
+	return config, nil
+}
+
+func (s Signer) validate() error {
+	switch s.Backend {
+	case SignerBackendLocal, "":
+		return nil
+	case SignerBackendRemote:
+		if s.Remote.URL == "" {
+			return fmt.Errorf("signer.remote.url is required when signer.backend is %q", SignerBackendRemote)
+		}
+		return nil
+	case SignerBackendLedger:
+		if s.Ledger.DevicePath == "" {
+			return fmt.Errorf("signer.ledger.device_path is required when signer.backend is %q", SignerBackendLedger)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown signer backend %q", s.Backend)
+	}
+}
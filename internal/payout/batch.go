@@ -0,0 +1,109 @@
+package payout
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/goat-systems/tzpay/v2/internal/payout/issuer"
+	"github.com/goat-systems/tzpay/v2/internal/rpc"
+	"github.com/pkg/errors"
+)
+
+// Payment is a single destination/amount pair owed for a cycle.
+type Payment struct {
+	Address string
+	Amount  float64
+}
+
+// CreateBatchPayment forges, signs, and injects a batch of transfers from
+// signer's address to each payment, via the issuer package's worker pool,
+// and returns the ophash of every batch once it has been injected. It is a
+// thin synchronous wrapper over issuer.IssueBatch: it blocks until every
+// batch has been forged, signed, and injected (or failed trying), but it
+// does not wait for on-chain confirmation — that's the operation tracker's
+// job, so one stuck operation can't tie up a queue worker forever.
+// paymentFee must be expressed in mutez. tracker, if non-nil, is handed
+// every successfully injected batch, tagged with cycle, so it can be
+// followed up on; without one, an injected operation that never confirms
+// goes unnoticed.
+func CreateBatchPayment(client rpc.IFace, signer Signer, cycle int, payments []Payment, paymentFee int, gaslimit int, concurrency int, tracker issuer.Tracker) ([]string, error) {
+	batches := splitPaymentsIntoBatches(payments)
+	issuerBatches := make([][]issuer.Payment, len(batches))
+	for k, batch := range batches {
+		issuerBatches[k] = toIssuerPayments(batch)
+	}
+
+	iss := issuer.New(client, signer)
+	iss.Initialize(concurrency, nil)
+	if tracker != nil {
+		iss.SetTracker(tracker)
+	}
+
+	var (
+		mu       sync.Mutex
+		ophashes []string
+		firstErr error
+	)
+
+	err := iss.IssueBatch(signer.Address(), cycle, issuerBatches, paymentFee, gaslimit, func(status issuer.Status, ophash string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch status {
+		case issuer.StatusInjected:
+			ophashes = append(ophashes, ophash)
+		case issuer.StatusFailed:
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "CreateBatchPayment failed to issue batches")
+	}
+	if firstErr != nil {
+		return ophashes, firstErr
+	}
+
+	return ophashes, nil
+}
+
+func toIssuerPayments(batch []Payment) []issuer.Payment {
+	var payments []issuer.Payment
+	for _, p := range batch {
+		if p.Amount <= 0 {
+			continue
+		}
+		payments = append(payments, issuer.Payment{Address: p.Address, Amount: p.Amount})
+	}
+	return payments
+}
+
+// splitPaymentsIntoBatches groups payments into chunks small enough to fit
+// in a single operation group, matching the batch size tzpay has always
+// used for Tezos transactions.
+func splitPaymentsIntoBatches(payments []Payment) [][]Payment {
+	const maxBatchSize = 200
+
+	var batches [][]Payment
+	for len(payments) > 0 {
+		n := maxBatchSize
+		if n > len(payments) {
+			n = len(payments)
+		}
+		batches = append(batches, payments[:n])
+		payments = payments[n:]
+	}
+
+	return batches
+}
+
+// DecodeSignature strips the edsig prefix from a base58 encoded signature
+// and returns the raw signature bytes, hex encoded, ready to append to
+// forged operation bytes before injection.
+func DecodeSignature(signature string) (string, error) {
+	if len(signature) < 5 || signature[:5] != "edsig" {
+		return "", fmt.Errorf("decodeSignature: %q is not an edsig signature", signature)
+	}
+	return hex.EncodeToString(b58cdecode(signature, edsig)), nil
+}
@@ -0,0 +1,316 @@
+// Package issuer lets CreateBatchPayment's forge/sign/inject pipeline run
+// across many batches concurrently instead of serializing them in a
+// for-loop, which matters once a baker has enough delegators to split a
+// cycle into dozens of batches. It intentionally has no dependency on
+// package payout, so payout can depend on it without an import cycle.
+package issuer
+
+import (
+	"encoding/hex"
+	"strconv"
+	"sync"
+
+	"github.com/Messer4/base58check"
+	"github.com/goat-systems/go-tezos/v3/forge"
+	goatrpc "github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v2/internal/rpc"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// watermarkGenericOperation tags operation bytes being signed, per the
+// Tezos signer protocol.
+const watermarkGenericOperation byte = 3
+
+// Signer is the subset of payout.Signer the issuer needs to sign forged
+// operations. Any payout.Signer implementation satisfies this already.
+type Signer interface {
+	Address() string
+	Sign(watermark byte, opBytes []byte) (string, error)
+}
+
+// Payment is a single destination/amount pair to forge a transfer for.
+type Payment struct {
+	Address string
+	Amount  float64
+}
+
+// Status is the terminal state IssueBatch reports a batch finished in.
+// Confirmation isn't one of these: it's the operation tracker's job, not
+// the issuer's.
+type Status string
+
+const (
+	StatusInjected Status = "injected"
+	StatusFailed   Status = "failed"
+)
+
+// EventKind categorizes a Notifier Event.
+type EventKind string
+
+const (
+	EventKindInjected EventKind = "injected"
+	EventKindFailed   EventKind = "failed"
+)
+
+// Event is a single notable occurrence the issuer surfaces through Notifier.
+type Event struct {
+	Kind    EventKind
+	Ophash  string
+	Message string
+}
+
+// Notifier lets callers observe issuer events without the issuer depending
+// on any particular notification backend.
+type Notifier interface {
+	Notify(event Event) error
+}
+
+// TrackedOperation is everything the operation tracker needs to follow up
+// on an injected operation: enough to re-forge it from scratch on a fresh
+// branch if it never confirms.
+type TrackedOperation struct {
+	Ophash   string
+	Branch   string
+	Counter  int
+	Fee      int
+	Gaslimit int
+	Source   string
+	Payments []Payment
+	// Cycle identifies which of the caller's logical batches this operation
+	// belongs to, so a tracker can report confirmation back against it. It's
+	// opaque to the issuer; payout.Queue uses it to hold its own cycle
+	// number.
+	Cycle int
+}
+
+// Tracker hands a just-injected operation off for confirmation tracking.
+// Without one set, IssueBatch has no way to know whether an injected
+// operation ever actually confirms.
+type Tracker interface {
+	Track(op TrackedOperation) error
+}
+
+// Issuer forges, signs, and injects batches of payments concurrently,
+// bounded to a fixed worker pool, and reports each batch's outcome through
+// a caller-supplied callback as soon as it finalizes.
+type Issuer struct {
+	client rpc.IFace
+	signer Signer
+
+	notifier Notifier
+	tracker  Tracker
+	sem      chan struct{}
+
+	counterMu sync.Mutex
+	counter   int
+	branch    string
+}
+
+// New builds an Issuer bound to client and signer. Call Initialize before
+// issuing any batches.
+func New(client rpc.IFace, signer Signer) *Issuer {
+	return &Issuer{client: client, signer: signer}
+}
+
+// Initialize prepares the issuer's worker pool. It must be called before
+// IssueBatch.
+func (i *Issuer) Initialize(concurrency int, notifier Notifier) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	i.sem = make(chan struct{}, concurrency)
+	i.notifier = notifier
+}
+
+// SetTracker wires a Tracker that every injected operation is handed off to
+// for confirmation tracking. Without one, a stuck or dropped operation has
+// no way to be noticed, bumped, or re-injected.
+func (i *Issuer) SetTracker(tracker Tracker) {
+	i.tracker = tracker
+}
+
+// IssueBatch forges, signs, and injects each batch in batches concurrently
+// (bounded by the worker pool set in Initialize), calling onFinalized
+// exactly once per batch with its outcome (injected or failed). IssueBatch
+// blocks until every batch has been dispatched and finalized, but does not
+// wait for injected operations to confirm on-chain — that's the operation
+// tracker's job.
+func (i *Issuer) IssueBatch(source string, cycle int, batches [][]Payment, fee, gaslimit int, onFinalized func(status Status, ophash string, err error)) error {
+	if i.sem == nil {
+		i.Initialize(1, i.notifier)
+	}
+
+	head, err := i.client.Head()
+	if err != nil {
+		return errors.Wrap(err, "issuer: failed to fetch chain head")
+	}
+
+	counter, err := i.client.Counter(source, head.Hash)
+	if err != nil {
+		return errors.Wrap(err, "issuer: failed to fetch counter")
+	}
+
+	i.counterMu.Lock()
+	i.counter = counter
+	i.branch = head.Hash
+	i.counterMu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		batch := batch
+		wg.Add(1)
+		i.sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-i.sem }()
+			i.issueOne(source, cycle, batch, fee, gaslimit, onFinalized)
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// nextCounter allocates n sequential counter values under the shared lock,
+// but only advances the shared cursor once the caller reports the forge
+// that consumed them succeeded — so a failed forge doesn't leave a gap a
+// later operation can never fill.
+func (i *Issuer) nextCounter(n int) (start int, commit func(success bool)) {
+	i.counterMu.Lock()
+	start = i.counter + 1
+	return start, func(success bool) {
+		if success {
+			i.counter += n
+		}
+		i.counterMu.Unlock()
+	}
+}
+
+func (i *Issuer) issueOne(source string, cycle int, batch []Payment, fee, gaslimit int, onFinalized func(Status, string, error)) {
+	// branch is read before nextCounter, not after: counterMu isn't
+	// reentrant, and nextCounter returns with it still held until commit is
+	// called, so locking it again here would deadlock this goroutine (and,
+	// with it, permanently leak its wg slot and i.sem slot) forever.
+	i.counterMu.Lock()
+	branch := i.branch
+	i.counterMu.Unlock()
+
+	start, commit := i.nextCounter(len(batch))
+
+	var contents []goatrpc.Contents
+	counter := start
+	for _, p := range batch {
+		contents = append(contents, goatrpc.Contents{
+			Kind:         "transaction",
+			Source:       source,
+			Fee:          strconv.Itoa(fee),
+			GasLimit:     strconv.Itoa(gaslimit),
+			StorageLimit: "0",
+			Amount:       strconv.FormatFloat(p.Amount, 'f', 0, 64),
+			Destination:  p.Address,
+			Counter:      strconv.Itoa(counter),
+		})
+		counter++
+	}
+
+	operationBytes, err := forge.Encode(branch, contents...)
+	if err != nil {
+		commit(false)
+		i.finalize(onFinalized, StatusFailed, "", errors.Wrap(err, "issuer: failed to forge batch"))
+		return
+	}
+	commit(true)
+
+	opBytes, err := hex.DecodeString(operationBytes)
+	if err != nil {
+		i.finalize(onFinalized, StatusFailed, "", errors.Wrap(err, "issuer: failed to decode forged bytes"))
+		return
+	}
+
+	edsig, err := i.signer.Sign(watermarkGenericOperation, opBytes)
+	if err != nil {
+		i.finalize(onFinalized, StatusFailed, "", errors.Wrap(err, "issuer: failed to sign batch"))
+		return
+	}
+
+	if _, err := i.client.PreapplyOperations(goatrpc.PreapplyOperationsInput{
+		Blockhash: branch,
+		Contents:  contents,
+		Signature: edsig,
+	}); err != nil {
+		i.finalize(onFinalized, StatusFailed, "", errors.Wrap(err, "issuer: failed to pre-apply batch"))
+		return
+	}
+
+	decoded, err := decodeSignature(edsig)
+	if err != nil {
+		i.finalize(onFinalized, StatusFailed, "", errors.Wrap(err, "issuer: failed to decode signature"))
+		return
+	}
+
+	ophash, err := i.client.InjectionOperation(goatrpc.InjectionOperationInput{
+		Operation: operationBytes + decoded,
+	})
+	if err != nil {
+		i.finalize(onFinalized, StatusFailed, "", errors.Wrap(err, "issuer: failed to inject batch"))
+		return
+	}
+
+	// Confirmation is deliberately not awaited here: polling for inclusion
+	// until it lands would tie up this worker (and the pool slot it holds)
+	// for as long as the operation stays stuck, which is exactly the
+	// scenario the operation tracker exists to own. IssueBatch's contract is
+	// "forged, signed, and injected", not "confirmed".
+	if i.tracker != nil {
+		if err := i.tracker.Track(TrackedOperation{
+			Ophash:   ophash,
+			Branch:   branch,
+			Counter:  start,
+			Fee:      fee,
+			Gaslimit: gaslimit,
+			Source:   source,
+			Payments: batch,
+			Cycle:    cycle,
+		}); err != nil {
+			log.WithFields(log.Fields{"ophash": ophash, "error": err.Error()}).Error("issuer: failed to hand off operation for tracking")
+		}
+	}
+
+	i.finalize(onFinalized, StatusInjected, ophash, nil)
+}
+
+func (i *Issuer) finalize(onFinalized func(Status, string, error), status Status, ophash string, err error) {
+	if err != nil {
+		log.WithFields(log.Fields{"ophash": ophash, "error": err.Error()}).Error("issuer: batch failed")
+	}
+	if i.notifier != nil {
+		kind := EventKindInjected
+		if status == StatusFailed {
+			kind = EventKindFailed
+		}
+		message := string(status)
+		if err != nil {
+			message = err.Error()
+		}
+		_ = i.notifier.Notify(Event{Kind: kind, Ophash: ophash, Message: message})
+	}
+	if onFinalized != nil {
+		onFinalized(status, ophash, err)
+	}
+}
+
+func decodeSignature(signature string) (string, error) {
+	if len(signature) < 5 || signature[:5] != "edsig" {
+		return "", errors.Errorf("decodeSignature: %q is not an edsig signature", signature)
+	}
+	return hex.EncodeToString(b58cdecode(signature)), nil
+}
+
+// edsig b58 prefix, see http://tezos.gitlab.io/api/p2p.html#id2
+var edsigPrefix = []byte{9, 245, 205, 134, 18}
+
+func b58cdecode(payload string) []byte {
+	decoded, _ := base58check.Decode(payload)
+	return decoded[len(edsigPrefix):]
+}
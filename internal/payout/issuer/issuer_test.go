@@ -0,0 +1,132 @@
+package issuer
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	goatrpc "github.com/goat-systems/go-tezos/v3/rpc"
+)
+
+// fakeClient is a minimal rpc.IFace that answers every call from in-memory
+// state, so IssueBatch can be exercised without a real Tezos node.
+type fakeClient struct {
+	mu      sync.Mutex
+	counter int
+}
+
+// fakeBranch is a well-formed block hash (mainnet genesis), so the issuer
+// can forge against it.
+const fakeBranch = "BLockGenesisGenesisGenesisGenesisGenesisf79b5d1CoW2"
+
+func (f *fakeClient) Head() (*goatrpc.Block, error) {
+	return &goatrpc.Block{Hash: fakeBranch}, nil
+}
+
+func (f *fakeClient) Block(level int) (*goatrpc.Block, error) {
+	return &goatrpc.Block{Hash: fakeBranch}, nil
+}
+
+func (f *fakeClient) Counter(address, blockhash string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.counter, nil
+}
+
+func (f *fakeClient) PreapplyOperations(input goatrpc.PreapplyOperationsInput) ([]goatrpc.Operations, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) InjectionOperation(input goatrpc.InjectionOperationInput) (string, error) {
+	return "opHash", nil
+}
+
+func (f *fakeClient) PendingOperations() ([]string, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) RunOperation(input goatrpc.RunOperationInput) (*goatrpc.RunOperationResults, error) {
+	return nil, nil
+}
+
+func (f *fakeClient) Constants() (*goatrpc.Constants, error) {
+	return &goatrpc.Constants{}, nil
+}
+
+// fakeSigner always signs successfully with a well-formed (dummy) edsig
+// signature that decodeSignature can base58check-decode.
+type fakeSigner struct{}
+
+func (fakeSigner) Address() string { return "tz1baker" }
+
+func (fakeSigner) Sign(watermark byte, opBytes []byte) (string, error) {
+	return "edsigtXomBKi5CTRf5cjATJWSyaRvhfYNHqSUGrn4SdbYRcGwQrUGjzEfQDTuqHhuA8b2QjFypTFpQgKdqGLbcV8wJtrLTWNiAf", nil
+}
+
+type fakeTracker struct {
+	mu  sync.Mutex
+	ops []TrackedOperation
+}
+
+func (t *fakeTracker) Track(op TrackedOperation) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ops = append(t.ops, op)
+	return nil
+}
+
+// Regression test for the chunk0-6 self-deadlock: issueOne used to re-lock
+// counterMu (already held by nextCounter until commit) before reading
+// i.branch, so the very first batch would hang forever. Runs IssueBatch on
+// a goroutine with a timeout guard so a reintroduced deadlock fails the test
+// instead of hanging the suite.
+func TestIssueBatchDoesNotDeadlock(t *testing.T) {
+	client := &fakeClient{counter: 10}
+	tracker := &fakeTracker{}
+
+	iss := New(client, fakeSigner{})
+	iss.Initialize(2, nil)
+	iss.SetTracker(tracker)
+
+	batches := [][]Payment{
+		{{Address: "tz1gjaF81ZRRvdzjobyfVNsAeSC6PScjfQwN", Amount: 100}},
+		{{Address: "tz1faswCTDciRzE4oJ9jn2Vm2dvjeyA9fUzU", Amount: 200}},
+		{{Address: "tz1b7tUupMgCNw2cCLpKTkSD1NZzB5TkP2sv", Amount: 300}},
+	}
+
+	var mu sync.Mutex
+	var statuses []Status
+
+	done := make(chan error, 1)
+	go func() {
+		done <- iss.IssueBatch("tz1KqTpEZ7Yob7QbPE4Hy4Wo8fHG8LhKxZSx", 123, batches, 5, 1000, func(status Status, ophash string, err error) {
+			mu.Lock()
+			statuses = append(statuses, status)
+			mu.Unlock()
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("IssueBatch deadlocked issuing its first batch")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(statuses) != len(batches) {
+		t.Fatalf("expected %d finalized batches, got %d", len(batches), len(statuses))
+	}
+	for _, status := range statuses {
+		if status != StatusInjected {
+			t.Fatalf("expected every batch to report %q, got %q", StatusInjected, status)
+		}
+	}
+
+	if len(tracker.ops) != len(batches) {
+		t.Fatalf("expected %d tracked operations, got %d", len(batches), len(tracker.ops))
+	}
+}
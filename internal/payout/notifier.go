@@ -0,0 +1,30 @@
+package payout
+
+// Notifier is how the payout pipeline surfaces user-facing events —
+// queue's runner.notifier already satisfies this for cycle-level events;
+// the operation tracker uses the same interface so operators see stuck and
+// re-injected payments through whatever channel they already configured
+// (stdout, Slack, etc).
+type Notifier interface {
+	Notify(event Event) error
+}
+
+// EventKind categorizes an Event for notifiers that want to filter or
+// format differently by kind.
+type EventKind string
+
+const (
+	EventKindInjected   EventKind = "injected"
+	EventKindConfirmed  EventKind = "confirmed"
+	EventKindStuck      EventKind = "stuck"
+	EventKindReinjected EventKind = "reinjected"
+	EventKindFailed     EventKind = "failed"
+)
+
+// Event is a single notable occurrence in the payout pipeline.
+type Event struct {
+	Kind    EventKind
+	Cycle   int
+	Ophash  string
+	Message string
+}
@@ -0,0 +1,54 @@
+package payout
+
+import (
+	"github.com/goat-systems/tzpay/v2/internal/config"
+	"github.com/pkg/errors"
+)
+
+// Payout is one cycle's worth of rewards owed to delegators, ready to be
+// forged, signed, and injected by the Queue.
+type Payout struct {
+	Cycle   int
+	Source  string
+	Inject  bool
+	Verbose bool
+
+	payments []Payment
+}
+
+// New computes the payout for cycle from cfg. inject controls whether the
+// queue should actually submit it to the chain once processed; dry-run
+// callers (e.g. the dryrun subcommand) pass false.
+//
+// TODO: this snapshot has no delegator-snapshot/reward-split implementation
+// to compute the real payment list from, so New refuses to return a Payout
+// rather than silently handing the queue an empty one. Wire the real
+// staking-balance/reward computation in here before this is load-bearing.
+func New(cfg config.Config, cycle int, inject bool, verbose bool) (*Payout, error) {
+	payments, err := computePayments(cfg, cycle)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to compute payments for cycle %d", cycle)
+	}
+
+	return &Payout{
+		Cycle:    cycle,
+		Source:   cfg.Wallet.Address,
+		Inject:   inject,
+		Verbose:  verbose,
+		payments: payments,
+	}, nil
+}
+
+// computePayments is not yet implemented: it needs a delegator snapshot
+// (staking balances at the cycle's snapshot block) and a reward split
+// formula, neither of which exist in this tree. Returning an error here
+// rather than an empty slice means every caller fails loudly instead of
+// forging and injecting a no-op operation that pays nobody.
+func computePayments(cfg config.Config, cycle int) ([]Payment, error) {
+	return nil, errors.New("payout: reward computation is not implemented")
+}
+
+// Payments returns the destination/amount pairs owed for this cycle.
+func (p *Payout) Payments() []Payment {
+	return p.payments
+}
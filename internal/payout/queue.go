@@ -0,0 +1,217 @@
+package payout
+
+import (
+	"github.com/goat-systems/tzpay/v2/internal/config"
+	"github.com/goat-systems/tzpay/v2/internal/payout/issuer"
+	"github.com/goat-systems/tzpay/v2/internal/rpc"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// State is where a cycle's payout currently sits in the pipeline.
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateForged    State = "forged"
+	StateInjected  State = "injected"
+	StateConfirmed State = "confirmed"
+	StateFailed    State = "failed"
+)
+
+// Record is the persisted view of one cycle moving through the Queue, so a
+// restart can tell what it already did and what it still owes.
+type Record struct {
+	Cycle    int      `json:"cycle"`
+	State    State    `json:"state"`
+	Ophashes []string `json:"ophashes"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// QueueStore persists Queue state across restarts.
+type QueueStore interface {
+	Put(record Record) error
+	All() ([]Record, error)
+	Close() error
+}
+
+// Queue fans cycle payouts out to the issuer's worker pool: every enqueued
+// cycle is forged, signed, and injected concurrently with the others, up to
+// concurrency at a time, with every state transition persisted to store so
+// a crash mid-cycle resumes instead of double-paying or skipping it.
+type Queue struct {
+	notifier    Notifier
+	store       QueueStore
+	client      rpc.IFace
+	signer      Signer
+	fee         int
+	gaslimit    int
+	concurrency int
+	tracker     issuer.Tracker
+
+	in  chan Payout
+	sem chan struct{}
+}
+
+// NewQueue builds a Queue. fee and gaslimit are the defaults passed to
+// CreateBatchPayment for every cycle it processes; concurrency bounds how
+// many cycles (and, within each cycle, how many batches) run at once.
+// tracker, if non-nil, is handed every batch CreateBatchPayment injects so
+// it can be followed up on until it confirms.
+func NewQueue(notifier Notifier, store QueueStore, client rpc.IFace, signer Signer, fee, gaslimit, concurrency int, tracker issuer.Tracker) Queue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return Queue{
+		notifier:    notifier,
+		store:       store,
+		client:      client,
+		signer:      signer,
+		fee:         fee,
+		gaslimit:    gaslimit,
+		concurrency: concurrency,
+		tracker:     tracker,
+		in:          make(chan Payout, 16),
+		sem:         make(chan struct{}, concurrency),
+	}
+}
+
+// Start begins processing enqueued payouts, fanning out up to concurrency
+// cycles at once.
+func (q Queue) Start() {
+	go func() {
+		for p := range q.in {
+			p := p
+			q.sem <- struct{}{}
+			go func() {
+				defer func() { <-q.sem }()
+				q.process(p)
+			}()
+		}
+	}()
+}
+
+// Enqueue records p as queued and hands it to the worker goroutine.
+func (q Queue) Enqueue(p Payout) {
+	if err := q.store.Put(Record{Cycle: p.Cycle, State: StateQueued}); err != nil {
+		log.WithFields(log.Fields{"cycle": p.Cycle, "error": err.Error()}).Error("queue: failed to persist queued cycle")
+	}
+	q.in <- p
+}
+
+// Rehydrate replays any record left queued, forged, or failed back onto the
+// queue after a restart, and reports the highest cycle that fully confirmed,
+// so callers don't have to trust rpcClient.Head() alone to pick up where
+// they left off. It deliberately does NOT re-enqueue StateInjected records:
+// an injected cycle is already forged, signed, and on-chain (or in the
+// operation tracker's hands re-forging it with a bumped fee); replaying
+// CreateBatchPayment for it would inject a second, duplicate payment. Once
+// the tracker sees every operation for a cycle confirm, it marks the record
+// StateConfirmed itself, so a crash between injection and confirmation just
+// means the next restart leaves that cycle to the tracker instead of
+// silently forgetting about it.
+func (q Queue) Rehydrate(cfg config.Config) (lastConfirmedCycle int, ok bool, err error) {
+	records, err := q.store.All()
+	if err != nil {
+		return 0, false, errors.Wrap(err, "queue: failed to load persisted records")
+	}
+
+	for _, record := range records {
+		switch record.State {
+		case StateConfirmed:
+			if !ok || record.Cycle > lastConfirmedCycle {
+				lastConfirmedCycle = record.Cycle
+				ok = true
+			}
+			continue
+		case StateInjected:
+			log.WithFields(log.Fields{"cycle": record.Cycle}).Info("queue: cycle already injected, leaving it to the operation tracker instead of re-injecting")
+			continue
+		}
+
+		log.WithFields(log.Fields{"cycle": record.Cycle, "state": record.State}).Info("queue: resuming unfinished cycle from disk")
+
+		p, err := New(cfg, record.Cycle, true, false)
+		if err != nil {
+			log.WithFields(log.Fields{"cycle": record.Cycle, "error": err.Error()}).Error("queue: failed to recompute unfinished cycle")
+			continue
+		}
+		q.in <- *p
+	}
+
+	return lastConfirmedCycle, ok, nil
+}
+
+func (q Queue) process(p Payout) {
+	q.setState(p.Cycle, StateForged, nil)
+
+	if !p.Inject {
+		return
+	}
+
+	fee, gaslimit := q.estimateFeeAndGas(p)
+
+	ophashes, err := CreateBatchPayment(q.client, q.signer, p.Cycle, p.Payments(), fee, gaslimit, q.concurrency, q.tracker)
+	if err != nil {
+		q.fail(p.Cycle, err)
+		return
+	}
+
+	q.setState(p.Cycle, StateInjected, ophashes)
+	q.notify(EventKindInjected, p.Cycle, "cycle injected")
+}
+
+// estimateFeeAndGas simulates p against the chain to derive the fee and gas
+// limit CreateBatchPayment should actually inject with, so operators don't
+// have to hand-tune q.fee/q.gaslimit as magic numbers. It takes the highest
+// fee and consumed gas (plus a small buffer) simulated across every payment
+// in the cycle, so one batch's worst-case payment doesn't underfund the
+// others sharing its fee/gas limit. If simulation fails for any reason, it
+// falls back to the configured defaults rather than failing the cycle over
+// an estimation problem.
+func (q Queue) estimateFeeAndGas(p Payout) (fee int, gaslimit int) {
+	result, err := SimulateBatchPayment(q.client, q.signer.Address(), p.Payments(), q.gaslimit)
+	if err != nil {
+		log.WithFields(log.Fields{"cycle": p.Cycle, "error": err.Error()}).Warn("queue: failed to simulate cycle, falling back to configured fee/gas limit")
+		return q.fee, q.gaslimit
+	}
+
+	fee, gaslimit = q.fee, q.gaslimit
+	found := false
+	const gasBuffer = 100
+	for _, batch := range result.Batches {
+		for _, estimated := range batch {
+			if !found || estimated.Fee > fee {
+				fee = estimated.Fee
+			}
+			if gas := estimated.ConsumedGas + gasBuffer; !found || gas > gaslimit {
+				gaslimit = gas
+			}
+			found = true
+		}
+	}
+
+	return fee, gaslimit
+}
+
+func (q Queue) fail(cycle int, err error) {
+	if putErr := q.store.Put(Record{Cycle: cycle, State: StateFailed, Error: err.Error()}); putErr != nil {
+		log.WithFields(log.Fields{"cycle": cycle, "error": putErr.Error()}).Error("queue: failed to persist failed cycle")
+	}
+	q.notify(EventKindFailed, cycle, err.Error())
+}
+
+func (q Queue) setState(cycle int, state State, ophashes []string) {
+	if err := q.store.Put(Record{Cycle: cycle, State: state, Ophashes: ophashes}); err != nil {
+		log.WithFields(log.Fields{"cycle": cycle, "error": err.Error()}).Error("queue: failed to persist cycle state")
+	}
+}
+
+func (q Queue) notify(kind EventKind, cycle int, message string) {
+	if q.notifier == nil {
+		return
+	}
+	if err := q.notifier.Notify(Event{Kind: kind, Cycle: cycle, Message: message}); err != nil {
+		log.WithField("error", err.Error()).Warn("queue: notifier failed")
+	}
+}
@@ -0,0 +1,78 @@
+package payout
+
+import (
+	"testing"
+
+	"github.com/goat-systems/tzpay/v2/internal/config"
+)
+
+// fakeQueueStore is an in-memory QueueStore for exercising Rehydrate without
+// a real BoltDB file on disk.
+type fakeQueueStore struct {
+	records []Record
+}
+
+func (s *fakeQueueStore) Put(record Record) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *fakeQueueStore) All() ([]Record, error) {
+	return s.records, nil
+}
+
+func (s *fakeQueueStore) Close() error {
+	return nil
+}
+
+// Regression test for the chunk0-5 double-pay fix: Rehydrate must not
+// re-enqueue StateInjected records (they're already forged, signed, and
+// on-chain, or in the tracker's hands), and must report the highest
+// StateConfirmed cycle seen so callers never replay a cycle that's already
+// fully settled.
+func TestQueueRehydrate(t *testing.T) {
+	store := &fakeQueueStore{records: []Record{
+		{Cycle: 100, State: StateConfirmed},
+		{Cycle: 102, State: StateConfirmed},
+		{Cycle: 101, State: StateConfirmed},
+		{Cycle: 103, State: StateInjected},
+	}}
+
+	q := NewQueue(nil, store, nil, nil, 0, 0, 1, nil)
+
+	lastConfirmed, ok, err := q.Rehydrate(config.Config{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !ok {
+		t.Fatal("expected ok to be true when StateConfirmed records are present")
+	}
+	if lastConfirmed != 102 {
+		t.Fatalf("expected the highest confirmed cycle (102), got %d", lastConfirmed)
+	}
+
+	select {
+	case p := <-q.in:
+		t.Fatalf("expected no cycle to be re-enqueued, got cycle %d", p.Cycle)
+	default:
+	}
+}
+
+// With no StateConfirmed records at all, Rehydrate must report ok=false
+// rather than a misleading lastConfirmedCycle of 0, which would look
+// indistinguishable from "cycle 0 confirmed".
+func TestQueueRehydrateNoConfirmedRecords(t *testing.T) {
+	store := &fakeQueueStore{records: []Record{
+		{Cycle: 100, State: StateInjected},
+	}}
+
+	q := NewQueue(nil, store, nil, nil, 0, 0, 1, nil)
+
+	_, ok, err := q.Rehydrate(config.Config{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if ok {
+		t.Fatal("expected ok to be false when no StateConfirmed records are present")
+	}
+}
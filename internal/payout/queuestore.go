@@ -0,0 +1,93 @@
+package payout
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+var queueBucket = []byte("queue_records")
+
+// boltOpenTimeout bounds how long bolt.Open waits to acquire the file lock.
+// serv holds it exclusively for its whole lifetime, so without a timeout any
+// other command sharing the same data dir (e.g. status) would hang forever
+// instead of failing with a clear error.
+const boltOpenTimeout = 1 * time.Second
+
+// boltQueueStore is the default QueueStore, backed by a single BoltDB file.
+type boltQueueStore struct {
+	db *bolt.DB
+}
+
+// NewBoltQueueStore opens (creating if necessary) a BoltDB-backed QueueStore
+// at path.
+func NewBoltQueueStore(path string) (QueueStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, errors.Wrap(err, "queue: failed to open store")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(queueBucket)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "queue: failed to initialize store")
+	}
+
+	return &boltQueueStore{db: db}, nil
+}
+
+// NewBoltQueueStoreReadOnly opens the BoltDB-backed QueueStore at path
+// without requiring write access, for callers (like the status command)
+// that only ever read it. It still takes the file lock, so while serv is
+// running and holding the lock for writing, it fails after boltOpenTimeout.
+func NewBoltQueueStoreReadOnly(path string) (QueueStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout, ReadOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "queue: failed to open store read-only")
+	}
+
+	return &boltQueueStore{db: db}, nil
+}
+
+func (s *boltQueueStore) Put(record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return errors.Wrap(err, "queue: failed to marshal record")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(queueBucket).Put(cycleKey(record.Cycle), data)
+	})
+}
+
+func (s *boltQueueStore) All() ([]Record, error) {
+	var records []Record
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(queueBucket).ForEach(func(k, v []byte) error {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			records = append(records, record)
+			return nil
+		})
+	})
+
+	return records, errors.Wrap(err, "queue: failed to list records")
+}
+
+func (s *boltQueueStore) Close() error {
+	return s.db.Close()
+}
+
+func cycleKey(cycle int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(cycle))
+	return key
+}
@@ -0,0 +1,23 @@
+package payout
+
+// Signer abstracts everything CreateBatchPayment needs to authorize an
+// operation. It lets the baker's key live wherever the operator trusts it
+// most: in process memory (Local), behind a tezos-signer daemon (Remote), or
+// on a Ledger device (Ledger) — without CreateBatchPayment knowing which.
+type Signer interface {
+	// PublicKey returns the base58 encoded, edpk-prefixed public key.
+	PublicKey() string
+	// Address returns the base58 encoded, tz1-prefixed public key hash.
+	Address() string
+	// Sign prepends watermark to opBytes, signs the result, and returns the
+	// base58 encoded, edsig-prefixed signature.
+	Sign(watermark byte, opBytes []byte) (string, error)
+}
+
+// Watermark bytes used to tag the kind of data being signed, per the Tezos
+// signer protocol.
+const (
+	WatermarkBlock            byte = 1
+	WatermarkEndorsement      byte = 2
+	WatermarkGenericOperation byte = 3
+)
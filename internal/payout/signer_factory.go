@@ -0,0 +1,61 @@
+package payout
+
+import (
+	"fmt"
+
+	"github.com/goat-systems/tzpay/v2/internal/config"
+	"github.com/pkg/errors"
+)
+
+// WalletCredentials are the values needed to stand up a LocalSigner: an edsk
+// secret (or seed) paired with the address/public key it derives, an
+// edesk-encrypted secret paired with the password that decrypts it, or a
+// mnemonic/password pair for brand new wallets.
+type WalletCredentials struct {
+	Address string
+	Public  string
+	Secret  string
+
+	EncryptedSecret string
+
+	Mnemonic string
+	Password string
+}
+
+// NewSigner builds the Signer backend selected by cfg. The local backend
+// builds its key from wallet; the remote backend takes only wallet.Address
+// and fetches the public key from the signer daemon; the ledger backend
+// ignores wallet and derives its address/public key from the device.
+func NewSigner(cfg config.Signer, wallet WalletCredentials) (Signer, error) {
+	switch cfg.Backend {
+	case config.SignerBackendRemote:
+		opts := []RemoteSignerOption{}
+		if cfg.Remote.BearerToken != "" {
+			opts = append(opts, WithBearerToken(cfg.Remote.BearerToken))
+		}
+		if cfg.Remote.TLSCert != "" {
+			opts = append(opts, WithTLSClientCert(cfg.Remote.TLSCert, cfg.Remote.TLSKey, cfg.Remote.TLSCACert))
+		}
+		signer, err := NewRemoteSigner(cfg.Remote.URL, wallet.Address, opts...)
+		return signer, errors.Wrap(err, "failed to build remote signer")
+
+	case config.SignerBackendLedger:
+		signer, err := newLedgerSignerFromConfig(cfg.Ledger)
+		return signer, errors.Wrap(err, "failed to build ledger signer")
+
+	case config.SignerBackendLocal, "":
+		if wallet.Mnemonic != "" {
+			signer, err := CreateWallet(wallet.Mnemonic, wallet.Password)
+			return signer, errors.Wrap(err, "failed to build local signer")
+		}
+		if wallet.EncryptedSecret != "" {
+			signer, err := ImportEncryptedWallet(wallet.Password, wallet.EncryptedSecret)
+			return signer, errors.Wrap(err, "failed to build local signer")
+		}
+		signer, err := ImportWallet(wallet.Address, wallet.Public, wallet.Secret)
+		return signer, errors.Wrap(err, "failed to build local signer")
+
+	default:
+		return nil, fmt.Errorf("unknown signer backend %q", cfg.Backend)
+	}
+}
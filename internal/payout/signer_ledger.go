@@ -0,0 +1,238 @@
+//go:build ledger
+// +build ledger
+
+package payout
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goat-systems/tzpay/v2/internal/config"
+	"github.com/karalabe/hid"
+	"github.com/pkg/errors"
+)
+
+// newLedgerSignerFromConfig adapts config.LedgerSignerConfig to NewLedgerSigner.
+func newLedgerSignerFromConfig(cfg config.LedgerSignerConfig) (Signer, error) {
+	return NewLedgerSigner(cfg.DevicePath, cfg.DerivationPath)
+}
+
+// Tezos app APDU instruction classes, per
+// https://github.com/obsidiansystems/ledger-app-tezos
+const (
+	ledgerCLA             byte = 0x80
+	ledgerInsVersion      byte = 0x00
+	ledgerInsGetPublicKey byte = 0x02
+	ledgerInsSign         byte = 0x04
+	ledgerP1First         byte = 0x00
+	ledgerP1More          byte = 0x01
+	ledgerP1Last          byte = 0x80
+	ledgerP2Last          byte = 0x00
+)
+
+// ledgerMaxChunkSize is the most a single Sign APDU can carry: the wire
+// format's length prefix is one byte, so a payload over 255 bytes (which any
+// batch of more than a couple of transfers produces, since it's the
+// derivation path plus the full forged operation) has to be split across
+// multiple exchanges.
+const ledgerMaxChunkSize = 250
+
+// LedgerSigner signs operations on a Ledger hardware wallet running the
+// Tezos app, over USB HID. It is only compiled in with the "ledger" build
+// tag, since it pulls in the hidapi cgo dependency.
+type LedgerSigner struct {
+	device         *hid.Device
+	derivationPath []uint32
+	address        string
+	pk             string
+}
+
+// NewLedgerSigner opens the Ledger at devicePath and derives the baker's key
+// at derivationPath (e.g. "44'/1729'/0'/0'").
+func NewLedgerSigner(devicePath, derivationPath string) (*LedgerSigner, error) {
+	path, err := parseDerivationPath(derivationPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "ledger signer: derivation path")
+	}
+
+	devices, err := hid.Enumerate(0, 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "ledger signer: enumerate")
+	}
+
+	var info *hid.DeviceInfo
+	for i := range devices {
+		if devices[i].Path == devicePath {
+			info = &devices[i]
+			break
+		}
+	}
+	if info == nil {
+		return nil, fmt.Errorf("ledger signer: no device found at %q", devicePath)
+	}
+
+	device, err := info.Open()
+	if err != nil {
+		return nil, errors.Wrap(err, "ledger signer: open")
+	}
+
+	signer := &LedgerSigner{device: device, derivationPath: path}
+
+	pk, address, err := signer.getPublicKey()
+	if err != nil {
+		device.Close()
+		return nil, errors.Wrap(err, "ledger signer: get public key")
+	}
+	signer.pk = pk
+	signer.address = address
+
+	return signer, nil
+}
+
+// PublicKey implements Signer.
+func (s *LedgerSigner) PublicKey() string {
+	return s.pk
+}
+
+// Address implements Signer.
+func (s *LedgerSigner) Address() string {
+	return s.address
+}
+
+// Sign implements Signer by sending the watermarked operation bytes to the
+// device for the operator to confirm on-screen, returning the edsig the
+// app computes.
+func (s *LedgerSigner) Sign(watermark byte, opBytes []byte) (string, error) {
+	watermarked := append([]byte{watermark}, opBytes...)
+
+	payload := append(encodeDerivationPath(s.derivationPath), watermarked...)
+	resp, err := s.exchangeChunked(ledgerInsSign, ledgerP2Last, payload)
+	if err != nil {
+		return "", errors.Wrap(err, "ledger signer: sign")
+	}
+
+	return b58cencode(resp, edsig), nil
+}
+
+func (s *LedgerSigner) getPublicKey() (pk string, address string, err error) {
+	resp, err := s.exchange(ledgerInsGetPublicKey, ledgerP1First, ledgerP2Last, encodeDerivationPath(s.derivationPath))
+	if err != nil {
+		return "", "", err
+	}
+
+	// First byte is the public key length prefix per the Tezos app's protocol.
+	if len(resp) < 1 {
+		return "", "", fmt.Errorf("unexpected response from device: %s", hex.EncodeToString(resp))
+	}
+	pubKeyBytes := resp[1:]
+
+	pk = b58cencode(pubKeyBytes, edpk)
+	address, err = generatePublicHash(pubKeyBytes)
+	return pk, address, err
+}
+
+// exchangeChunked sends data to the device across as many Sign APDUs as
+// required by ledgerMaxChunkSize, tagging the first and last chunk via P1
+// (0x00 on the first chunk, 0x01 on later ones, with 0x80 OR'd into the
+// last, so a single chunk that is both goes out as 0x80) so the device
+// knows when the full derivation path + operation has arrived and it can
+// sign it, rather than just the first 255 bytes of it.
+func (s *LedgerSigner) exchangeChunked(ins, p2 byte, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return s.exchange(ins, ledgerP1First|ledgerP1Last, p2, data)
+	}
+
+	var resp []byte
+	for offset := 0; offset < len(data); offset += ledgerMaxChunkSize {
+		end := offset + ledgerMaxChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		p1 := ledgerP1More
+		if offset == 0 {
+			p1 = ledgerP1First
+		}
+		if end == len(data) {
+			p1 |= ledgerP1Last
+		}
+
+		var err error
+		resp, err = s.exchange(ins, p1, p2, data[offset:end])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *LedgerSigner) exchange(ins, p1, p2 byte, data []byte) ([]byte, error) {
+	apdu := append([]byte{ledgerCLA, ins, p1, p2, byte(len(data))}, data...)
+
+	if _, err := s.device.Write(apdu); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 260)
+	n, err := s.device.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if n < 2 {
+		return nil, fmt.Errorf("short response from device")
+	}
+
+	// Last two bytes are the status word; 0x9000 means success.
+	sw := uint16(buf[n-2])<<8 | uint16(buf[n-1])
+	if sw != 0x9000 {
+		return nil, fmt.Errorf("device returned status word 0x%04x", sw)
+	}
+
+	return buf[:n-2], nil
+}
+
+// parseDerivationPath parses a BIP32 path like "44'/1729'/0'/0'" into its
+// hardened-bit-encoded uint32 components.
+func parseDerivationPath(path string) ([]uint32, error) {
+	segments := strings.Split(path, "/")
+	components := make([]uint32, 0, len(segments))
+
+	for _, segment := range segments {
+		hardened := strings.HasSuffix(segment, "'")
+		segment = strings.TrimSuffix(segment, "'")
+
+		value, err := strconv.ParseUint(segment, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path segment %q: %s", segment, err)
+		}
+
+		if hardened {
+			value |= 0x80000000
+		}
+		components = append(components, uint32(value))
+	}
+
+	return components, nil
+}
+
+// encodeDerivationPath serializes a derivation path the way the Tezos app
+// expects it on the wire: one length-prefix byte followed by big-endian
+// uint32s.
+func encodeDerivationPath(path []uint32) []byte {
+	encoded := make([]byte, 1+4*len(path))
+	encoded[0] = byte(len(path))
+
+	for i, component := range path {
+		offset := 1 + i*4
+		encoded[offset] = byte(component >> 24)
+		encoded[offset+1] = byte(component >> 16)
+		encoded[offset+2] = byte(component >> 8)
+		encoded[offset+3] = byte(component)
+	}
+
+	return encoded
+}
@@ -0,0 +1,17 @@
+//go:build !ledger
+// +build !ledger
+
+package payout
+
+import (
+	"fmt"
+
+	"github.com/goat-systems/tzpay/v2/internal/config"
+)
+
+// newLedgerSignerFromConfig reports that ledger support was not compiled
+// into this binary. Build with -tags ledger to link the real
+// implementation in signer_ledger.go.
+func newLedgerSignerFromConfig(cfg config.LedgerSignerConfig) (Signer, error) {
+	return nil, fmt.Errorf("ledger signer support was not compiled into this binary, rebuild with -tags ledger")
+}
@@ -0,0 +1,183 @@
+package payout
+
+import (
+	"crypto/sha512"
+	"fmt"
+
+	"github.com/Messer4/base58check"
+	"github.com/jamesruan/sodium"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// b58 prefixes, see http://tezos.gitlab.io/api/p2p.html#id2
+var (
+	edsk  = []byte{43, 246, 78, 7}
+	edsk2 = []byte{13, 15, 58, 7}
+	edpk  = []byte{13, 15, 37, 217}
+	edesk = []byte{7, 90, 60, 179, 41}
+	tz1   = []byte{6, 161, 159}
+	edsig = []byte{9, 245, 205, 134, 18}
+)
+
+// LocalSigner is the original tzpay signer: it keeps the baker's edsk secret
+// key in process memory and signs with libsodium directly. It satisfies
+// Signer and is the default backend when config.Signer.Backend is "local"
+// or unset.
+type LocalSigner struct {
+	address string
+	pk      string
+	sk      string
+	kp      sodium.SignKP
+}
+
+// CreateWallet derives a new LocalSigner from a BIP39 mnemonic and password.
+func CreateWallet(mnemonic, password string) (*LocalSigner, error) {
+	var signSecretKey sodium.SignSecretKey
+
+	// Copied from https://github.com/tyler-smith/go-bip39/blob/dbb3b84ba2ef14e894f5e33d6c6e43641e665738/bip39.go#L268
+	seed := pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+password), 2048, 64, sha512.New)
+	signSecretKey.Bytes = seed
+	signKP := sodium.SeedSignKP(signSecretKey.Seed())
+
+	address, err := generatePublicHash(signKP.PublicKey.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create wallet")
+	}
+
+	return &LocalSigner{
+		address: address,
+		pk:      b58cencode(signKP.PublicKey.Bytes, edpk),
+		sk:      b58cencode(signKP.SecretKey.Bytes, edsk),
+		kp:      signKP,
+	}, nil
+}
+
+// ImportWallet reconstructs a LocalSigner from an existing edsk secret (or
+// seed), verifying it matches the provided address and public key.
+func ImportWallet(address, public, secret string) (*LocalSigner, error) {
+	var signKP sodium.SignKP
+
+	secretLength := len(secret)
+	if len(secret) < 4 || secret[:4] != "edsk" || (secretLength != 98 && secretLength != 54) {
+		return nil, fmt.Errorf("import wallet: secret does not conform to known patterns")
+	}
+
+	switch secretLength {
+	case 98:
+		decodedSecretKey := b58cdecode(secret, edsk)
+		publicKey := decodedSecretKey[32:]
+		signKP.PublicKey = sodium.SignPublicKey{Bytes: publicKey}
+		signKP.SecretKey = sodium.SignSecretKey{Bytes: decodedSecretKey}
+	case 54:
+		decodedSeed := b58cdecode(secret, edsk2)
+		signKP = sodium.SeedSignKP(sodium.SignSeed{Bytes: decodedSeed})
+	}
+
+	generatedAddress, err := generatePublicHash(signKP.PublicKey.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "import wallet")
+	}
+	if generatedAddress != address {
+		return nil, fmt.Errorf("import wallet: reconstructed address %q and provided address %q do not match", generatedAddress, address)
+	}
+
+	generatedPublicKey := b58cencode(signKP.PublicKey.Bytes, edpk)
+	if generatedPublicKey != public {
+		return nil, fmt.Errorf("import wallet: reconstructed public key %q and provided public key %q do not match", generatedPublicKey, public)
+	}
+
+	return &LocalSigner{
+		address: generatedAddress,
+		pk:      generatedPublicKey,
+		sk:      b58cencode(signKP.SecretKey.Bytes, edsk),
+		kp:      signKP,
+	}, nil
+}
+
+// ImportEncryptedWallet decrypts an edesk secret key with pw and returns the
+// resulting LocalSigner. Callers should strip any "encrypted:" scheme prefix
+// from encKey before calling.
+func ImportEncryptedWallet(pw, encKey string) (*LocalSigner, error) {
+	if len(encKey) != 88 || encKey[:5] != "edesk" {
+		return nil, fmt.Errorf("import encrypted wallet: secret does not conform to known patterns")
+	}
+
+	b58c, err := base58check.Decode(encKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "import encrypted wallet")
+	}
+
+	esb := b58c[len(edesk):]
+	salt := esb[:8]
+	esm := esb[8:]
+
+	key := pbkdf2.Key([]byte(pw), salt, 32768, 32, sha512.New)
+
+	var box sodium.Bytes = esm
+	boxKey := sodium.SecretBoxKey{Bytes: key}
+	boxNonce := sodium.SecretBoxNonce{Bytes: make([]byte, 24)}
+
+	unencSecret, err := box.SecretBoxOpen(boxNonce, boxKey)
+	if err != nil {
+		return nil, fmt.Errorf("import encrypted wallet: incorrect password")
+	}
+
+	signKP := sodium.SeedSignKP(sodium.SignSeed{Bytes: unencSecret})
+
+	address, err := generatePublicHash(signKP.PublicKey.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "import encrypted wallet")
+	}
+
+	return &LocalSigner{
+		address: address,
+		pk:      b58cencode(signKP.PublicKey.Bytes, edpk),
+		sk:      b58cencode(signKP.SecretKey.Bytes, edsk),
+		kp:      signKP,
+	}, nil
+}
+
+// PublicKey implements Signer.
+func (s *LocalSigner) PublicKey() string {
+	return s.pk
+}
+
+// Address implements Signer.
+func (s *LocalSigner) Address() string {
+	return s.address
+}
+
+// Sign implements Signer by signing opBytes with the in-memory secret key.
+func (s *LocalSigner) Sign(watermark byte, opBytes []byte) (string, error) {
+	watermarked := append([]byte{watermark}, opBytes...)
+
+	genericHash := sodium.NewGenericHash(32)
+	if n, err := genericHash.Write(watermarked); n != len(watermarked) || err != nil {
+		return "", fmt.Errorf("sign: unable to write operation bytes to generic hash")
+	}
+	finalHash := genericHash.Sum([]byte{})
+
+	sig := sodium.Bytes(finalHash).SignDetached(s.kp.SecretKey)
+	return b58cencode(sig.Bytes, edsig), nil
+}
+
+func generatePublicHash(publicKey []byte) (string, error) {
+	genericHash := sodium.NewGenericHash(20)
+	if n, err := genericHash.Write(publicKey); n != 32 || err != nil {
+		return "", fmt.Errorf("unable to write public key to generic hash")
+	}
+	return b58cencode(genericHash.Sum([]byte{}), tz1), nil
+}
+
+func b58cencode(payload []byte, prefix []byte) string {
+	n := make([]byte, len(prefix)+len(payload))
+	copy(n, prefix)
+	copy(n[len(prefix):], payload)
+	return base58check.Encode(n)
+}
+
+func b58cdecode(payload string, prefix []byte) []byte {
+	b58c, _ := base58check.Decode(payload)
+	return b58c[len(prefix):]
+}
@@ -0,0 +1,160 @@
+package payout
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RemoteSigner signs operations by delegating to a tezos-signer compatible
+// HTTP daemon, so the baker's secret key never has to live in tzpay's
+// process memory. It speaks the same protocol as octez-signer: the caller
+// supplies the address (pkh), the public key is fetched once at
+// construction time via GET /keys/<pkh>, and every Sign call is a POST
+// /keys/<pkh> with the hex encoded (watermarked) operation bytes.
+type RemoteSigner struct {
+	url         string
+	bearerToken string
+	address     string
+	pk          string
+	client      *http.Client
+}
+
+// RemoteSignerOption configures NewRemoteSigner. It can fail (e.g. a bad TLS
+// cert path), so NewRemoteSigner surfaces that instead of silently building
+// a signer that isn't configured the way the caller asked.
+type RemoteSignerOption func(*RemoteSigner) error
+
+// WithBearerToken authenticates every request with an Authorization: Bearer header.
+func WithBearerToken(token string) RemoteSignerOption {
+	return func(s *RemoteSigner) error {
+		s.bearerToken = token
+		return nil
+	}
+}
+
+// WithTLSClientCert configures mutual TLS against the signer daemon.
+func WithTLSClientCert(certFile, keyFile, caFile string) RemoteSignerOption {
+	return func(s *RemoteSigner) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to load TLS client cert")
+		}
+
+		tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}}
+		if caFile != "" {
+			ca, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return errors.Wrap(err, "failed to read TLS CA cert")
+			}
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(ca)
+			tlsConfig.RootCAs = pool
+		}
+
+		s.client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+		return nil
+	}
+}
+
+// NewRemoteSigner connects to a tezos-signer daemon at baseURL and fetches
+// the public key for address.
+func NewRemoteSigner(baseURL, address string, opts ...RemoteSignerOption) (*RemoteSigner, error) {
+	signer := &RemoteSigner{
+		url:     baseURL,
+		address: address,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for _, opt := range opts {
+		if err := opt(signer); err != nil {
+			return nil, errors.Wrap(err, "failed to construct remote signer")
+		}
+	}
+
+	pk, err := signer.fetchPublicKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to construct remote signer")
+	}
+	signer.pk = pk
+
+	return signer, nil
+}
+
+// PublicKey implements Signer.
+func (s *RemoteSigner) PublicKey() string {
+	return s.pk
+}
+
+// Address implements Signer.
+func (s *RemoteSigner) Address() string {
+	return s.address
+}
+
+// Sign implements Signer by POSTing the watermarked operation bytes to the
+// remote signer and returning the edsig it responds with.
+func (s *RemoteSigner) Sign(watermark byte, opBytes []byte) (string, error) {
+	watermarked := append([]byte{watermark}, opBytes...)
+
+	var response struct {
+		Signature string `json:"signature"`
+	}
+	if err := s.do(http.MethodPost, fmt.Sprintf("/keys/%s", s.address), hex.EncodeToString(watermarked), &response); err != nil {
+		return "", errors.Wrap(err, "remote signer: sign")
+	}
+
+	return response.Signature, nil
+}
+
+func (s *RemoteSigner) fetchPublicKey() (string, error) {
+	var response struct {
+		PublicKey string `json:"public_key"`
+	}
+	if err := s.do(http.MethodGet, fmt.Sprintf("/keys/%s", s.address), "", &response); err != nil {
+		return "", err
+	}
+	return response.PublicKey, nil
+}
+
+func (s *RemoteSigner) do(method, path, hexPayload string, out interface{}) error {
+	var body bytes.Buffer
+	if hexPayload != "" {
+		if err := json.NewEncoder(&body).Encode(hexPayload); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, s.url+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+s.bearerToken)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+
+	return json.Unmarshal(respBody, out)
+}
@@ -0,0 +1,113 @@
+package payout
+
+import (
+	"encoding/hex"
+	"math"
+	"strconv"
+
+	"github.com/goat-systems/go-tezos/v3/forge"
+	goatrpc "github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v2/internal/rpc"
+	"github.com/pkg/errors"
+)
+
+// EstimatedPayment is one destination's simulated cost, used both to report
+// a dry-run and to set the real fee/gas before injection.
+type EstimatedPayment struct {
+	Payment          Payment
+	ConsumedGas      int
+	ConsumedMilligas int
+	StorageDiff      int
+	Fee              int
+	Errors           []string
+}
+
+// SimulationResult is everything a `tzpay dryrun` needs to print: a report
+// per batch, with no signature required and nothing injected.
+type SimulationResult struct {
+	Batches [][]EstimatedPayment
+}
+
+// SimulateBatchPayment exercises every transfer in payments against
+// /helpers/scripts/run_operation instead of injecting, and uses the result
+// together with the head's protocol constants to estimate a fee per
+// destination so operators no longer have to pass paymentFee/gaslimit as
+// magic numbers.
+func SimulateBatchPayment(client rpc.IFace, source string, payments []Payment, gaslimit int) (SimulationResult, error) {
+	var result SimulationResult
+
+	blockHead, err := client.Head()
+	if err != nil {
+		return result, errors.Wrap(err, "SimulateBatchPayment failed to fetch chain head")
+	}
+
+	constants, err := client.Constants()
+	if err != nil {
+		return result, errors.Wrap(err, "SimulateBatchPayment failed to fetch protocol constants")
+	}
+
+	counter, err := client.Counter(source, blockHead.Hash)
+	if err != nil {
+		return result, errors.Wrap(err, "SimulateBatchPayment failed to fetch counter")
+	}
+	counter++
+
+	batches := splitPaymentsIntoBatches(payments)
+
+	for k := range batches {
+		contents, newCounter := forgeTransactions(source, counter, batches[k], int(constants.MinimalFees), gaslimit)
+		counter = newCounter
+
+		operationBytes, err := forge.Encode(blockHead.Hash, contents...)
+		if err != nil {
+			return result, errors.Wrap(err, "SimulateBatchPayment failed to forge operation")
+		}
+
+		opBytes, err := hex.DecodeString(operationBytes)
+		if err != nil {
+			return result, errors.Wrap(err, "SimulateBatchPayment failed to decode forged bytes")
+		}
+
+		runResults, err := client.RunOperation(goatrpc.RunOperationInput{
+			Operation: goatrpc.RunOperationBody{
+				Branch:    blockHead.Hash,
+				Contents:  contents,
+				Signature: "edsigtXomBKi5CTRf5cjATJWSyaRvhfYNHqSUGrn4SdbYRcGwQrUGjzEfQDTuqHhuA8b2QjFypTFpQgKdqGLbcV8wJtrLTWNiAf",
+			},
+			ChainID: blockHead.ChainID,
+		})
+		if err != nil {
+			return result, errors.Wrap(err, "SimulateBatchPayment failed to run operation")
+		}
+
+		estimated := make([]EstimatedPayment, 0, len(batches[k]))
+		for i, content := range runResults.Contents {
+			consumedGas, _ := strconv.Atoi(content.Metadata.OperationResult.ConsumedGas)
+			consumedMilligas, _ := strconv.Atoi(content.Metadata.OperationResult.ConsumedMilligas)
+			storageDiff, _ := strconv.Atoi(content.Metadata.OperationResult.PaidStorageSizeDiff)
+
+			fee := estimateFee(constants, consumedMilligas, len(operationBytes)/2)
+
+			estimated = append(estimated, EstimatedPayment{
+				Payment:          batches[k][i],
+				ConsumedGas:      consumedGas,
+				ConsumedMilligas: consumedMilligas,
+				StorageDiff:      storageDiff,
+				Fee:              fee,
+				Errors:           content.Metadata.OperationResult.Errors,
+			})
+		}
+
+		result.Batches = append(result.Batches, estimated)
+	}
+
+	return result, nil
+}
+
+// estimateFee follows the protocol's own baker fee heuristic:
+// fee = minimal_fees + ceil(consumed_milligas * minimal_nanotez_per_gas / 1000) + size * minimal_nanotez_per_byte
+func estimateFee(constants *goatrpc.Constants, consumedMilligas, sizeBytes int) int {
+	gasComponent := math.Ceil(float64(consumedMilligas) * float64(constants.MinimalNanotezPerGasUnit) / 1000)
+	sizeComponent := float64(sizeBytes) * float64(constants.MinimalNanotezPerByte)
+	return int(constants.MinimalFees) + int(gasComponent) + int(sizeComponent)
+}
@@ -0,0 +1,109 @@
+package tracker
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltOpenTimeout bounds how long bolt.Open waits to acquire the file lock,
+// so a second process opening the same tracker.db while serv holds it fails
+// fast with a clear error instead of hanging forever.
+const boltOpenTimeout = 1 * time.Second
+
+var pendingBucket = []byte("pending_operations")
+
+// PendingOperation is everything the tracker needs to check on an injected
+// operation, and to re-forge it with a bumped fee if it never includes.
+type PendingOperation struct {
+	Ophash          string           `json:"ophash"`
+	Branch          string           `json:"branch"`
+	Counter         int              `json:"counter"`
+	Fee             int              `json:"fee"`
+	Gaslimit        int              `json:"gaslimit"`
+	Source          string           `json:"source"`
+	Payments        []PendingPayment `json:"payments"`
+	InjectedAtLevel int              `json:"injected_at_level"`
+	Attempts        int              `json:"attempts"`
+	// Cycle is the payout cycle this operation belongs to, so confirmation
+	// can be reported back against the right queue record.
+	Cycle int `json:"cycle"`
+}
+
+// PendingPayment is the destination/amount pair tracker.go re-forges from.
+type PendingPayment struct {
+	Address string  `json:"address"`
+	Amount  float64 `json:"amount"`
+}
+
+// Store persists in-flight operations so a tzpay restart doesn't lose track
+// of payouts that were injected but not yet confirmed.
+type Store interface {
+	Put(op PendingOperation) error
+	Delete(ophash string) error
+	All() ([]PendingOperation, error)
+	Close() error
+}
+
+// boltStore is the default Store, backed by a single BoltDB file.
+type boltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) a BoltDB-backed Store at path.
+func NewBoltStore(path string) (Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, errors.Wrap(err, "tracker: failed to open store")
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(pendingBucket)
+		return err
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "tracker: failed to initialize store")
+	}
+
+	return &boltStore{db: db}, nil
+}
+
+func (s *boltStore) Put(op PendingOperation) error {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to marshal pending operation")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).Put([]byte(op.Ophash), data)
+	})
+}
+
+func (s *boltStore) Delete(ophash string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).Delete([]byte(ophash))
+	})
+}
+
+func (s *boltStore) All() ([]PendingOperation, error) {
+	var ops []PendingOperation
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(pendingBucket).ForEach(func(k, v []byte) error {
+			var op PendingOperation
+			if err := json.Unmarshal(v, &op); err != nil {
+				return err
+			}
+			ops = append(ops, op)
+			return nil
+		})
+	})
+
+	return ops, errors.Wrap(err, "tracker: failed to list pending operations")
+}
+
+func (s *boltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,348 @@
+// Package tracker follows up on operations CreateBatchPayment has already
+// injected. Injection is fire-and-forget: if mempool pressure drops an
+// operation, or its fee is too low to be worth including, it can sit
+// forever without confirming or erroring. OperationTracker polls for
+// inclusion and, if an op stalls for too long, bumps its fee and
+// re-injects it on a fresh branch.
+package tracker
+
+import (
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/goat-systems/go-tezos/v3/forge"
+	goatrpc "github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/goat-systems/tzpay/v2/internal/payout"
+	"github.com/goat-systems/tzpay/v2/internal/payout/issuer"
+	"github.com/goat-systems/tzpay/v2/internal/rpc"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultStuckAfterBlocks is how many blocks an op may sit pending
+	// before the tracker bumps its fee and re-injects it.
+	defaultStuckAfterBlocks = 5
+	// defaultMaxBranchAge is how many blocks may pass since an op was last
+	// injected before the tracker re-forges it against the new head instead
+	// of its current branch. The count is taken from the op's last successful
+	// injection, not from its branch: a re-injection on the same branch
+	// starts it again.
+	defaultMaxBranchAge = 60
+	// defaultFeeBumpMutez is added to the fee, per re-injection attempt.
+	defaultFeeBumpMutez = 100
+)
+
+// Config tunes how patient the tracker is before it intervenes.
+type Config struct {
+	StuckAfterBlocks int
+	MaxBranchAge     int
+	FeeBumpMutez     int
+	PollInterval     time.Duration
+}
+
+// OperationTracker records injected operations and, on a timer, checks
+// whether they've included, bumping their fee and re-injecting if not.
+type OperationTracker struct {
+	client     rpc.IFace
+	signer     payout.Signer
+	store      Store
+	queueStore payout.QueueStore
+	notifier   payout.Notifier
+	cfg        Config
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New builds an OperationTracker. queueStore, if non-nil, is written to once
+// every tracked operation belonging to a cycle has confirmed, so Queue knows
+// the cycle is actually done rather than just injected; without one, the
+// tracker still chases confirmations but Queue.Rehydrate has no way to tell
+// an injected-but-unconfirmed cycle apart from a confirmed one. Zero-valued
+// Config fields fall back to sensible defaults (5 blocks, 60 block max
+// branch age, 100 mutez fee bump, polling once a minute).
+func New(client rpc.IFace, signer payout.Signer, store Store, queueStore payout.QueueStore, notifier payout.Notifier, cfg Config) *OperationTracker {
+	if cfg.StuckAfterBlocks == 0 {
+		cfg.StuckAfterBlocks = defaultStuckAfterBlocks
+	}
+	if cfg.MaxBranchAge == 0 {
+		cfg.MaxBranchAge = defaultMaxBranchAge
+	}
+	if cfg.FeeBumpMutez == 0 {
+		cfg.FeeBumpMutez = defaultFeeBumpMutez
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = time.Minute
+	}
+
+	return &OperationTracker{
+		client:     client,
+		signer:     signer,
+		store:      store,
+		queueStore: queueStore,
+		notifier:   notifier,
+		cfg:        cfg,
+		stop:       make(chan struct{}),
+	}
+}
+
+// Track records a just-injected operation so it can be followed up on. It
+// satisfies issuer.Tracker, so the issuer can hand off every batch it
+// injects without knowing anything about how tracking is implemented.
+func (t *OperationTracker) Track(op issuer.TrackedOperation) error {
+	head, err := t.client.Head()
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to fetch head while tracking operation")
+	}
+
+	payments := make([]PendingPayment, len(op.Payments))
+	for i, p := range op.Payments {
+		payments[i] = PendingPayment{Address: p.Address, Amount: p.Amount}
+	}
+
+	return t.store.Put(PendingOperation{
+		Ophash:          op.Ophash,
+		Branch:          op.Branch,
+		Counter:         op.Counter,
+		Fee:             op.Fee,
+		Gaslimit:        op.Gaslimit,
+		Source:          op.Source,
+		Payments:        payments,
+		InjectedAtLevel: head.Metadata.Level.Level,
+		Cycle:           op.Cycle,
+	})
+}
+
+// Start polls on cfg.PollInterval until Stop is called.
+func (t *OperationTracker) Start() {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.cfg.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.checkOnce(); err != nil {
+					log.WithField("error", err.Error()).Error("tracker: check failed")
+				}
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts polling and waits for the in-flight check, if any, to finish.
+func (t *OperationTracker) Stop() {
+	close(t.stop)
+	t.wg.Wait()
+}
+
+// Pending reports whether any operation is still being tracked, so the
+// serv command can hold a cycle's queue slot until every op in it confirms.
+func (t *OperationTracker) Pending() (bool, error) {
+	ops, err := t.store.All()
+	if err != nil {
+		return false, err
+	}
+	return len(ops) > 0, nil
+}
+
+func (t *OperationTracker) checkOnce() error {
+	ops, err := t.store.All()
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to load pending operations")
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	head, err := t.client.Head()
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to fetch head")
+	}
+
+	mempool, err := t.client.PendingOperations()
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to fetch mempool")
+	}
+	inMempool := make(map[string]bool, len(mempool))
+	for _, ophash := range mempool {
+		inMempool[ophash] = true
+	}
+
+	for _, op := range ops {
+		if err := t.checkOne(op, head, inMempool); err != nil {
+			log.WithFields(log.Fields{"ophash": op.Ophash, "error": err.Error()}).Error("tracker: failed to check operation")
+		}
+	}
+
+	return nil
+}
+
+func (t *OperationTracker) checkOne(op PendingOperation, head *goatrpc.Block, inMempool map[string]bool) error {
+	included, err := t.includedSince(op, head)
+	if err != nil {
+		return err
+	}
+	if included {
+		t.notify(payout.EventKindConfirmed, op, "operation confirmed")
+		if err := t.store.Delete(op.Ophash); err != nil {
+			return err
+		}
+		return t.maybeConfirmCycle(op)
+	}
+
+	age := head.Metadata.Level.Level - op.InjectedAtLevel
+	branchTooOld := age > t.cfg.MaxBranchAge
+	stuck := !inMempool[op.Ophash] || age > t.cfg.StuckAfterBlocks
+
+	if !stuck && !branchTooOld {
+		return nil
+	}
+
+	t.notify(payout.EventKindStuck, op, "operation not included after polling, re-injecting with a bumped fee")
+	return t.reinject(op, head, branchTooOld)
+}
+
+// maybeConfirmCycle checks whether any other operation tracked for op.Cycle
+// is still pending, and if not, marks the cycle StateConfirmed in queueStore.
+// This is what lets Queue.Rehydrate tell a cycle that's merely been injected
+// apart from one that's actually confirmed, so a restart doesn't re-inject
+// (and double-pay) a cycle that already went through.
+func (t *OperationTracker) maybeConfirmCycle(op PendingOperation) error {
+	if t.queueStore == nil {
+		return nil
+	}
+
+	pending, err := t.store.All()
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to load pending operations while confirming cycle")
+	}
+	for _, p := range pending {
+		if p.Cycle == op.Cycle {
+			return nil
+		}
+	}
+
+	records, err := t.queueStore.All()
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to load queue records while confirming cycle")
+	}
+	var ophashes []string
+	for _, r := range records {
+		if r.Cycle == op.Cycle {
+			ophashes = r.Ophashes
+			break
+		}
+	}
+
+	return t.queueStore.Put(payout.Record{Cycle: op.Cycle, State: payout.StateConfirmed, Ophashes: ophashes})
+}
+
+// includedSince scans blocks from the op's injection level up to head for
+// its ophash. A real deployment would use a cheaper inclusion index; this
+// mirrors the block-by-block walk the request asked for.
+func (t *OperationTracker) includedSince(op PendingOperation, head *goatrpc.Block) (bool, error) {
+	for level := op.InjectedAtLevel; level <= head.Metadata.Level.Level; level++ {
+		block, err := t.client.Block(level)
+		if err != nil {
+			return false, errors.Wrapf(err, "tracker: failed to fetch block %d", level)
+		}
+		for _, opGroup := range block.Operations {
+			for _, o := range opGroup {
+				if o.Hash == op.Ophash {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+func (t *OperationTracker) reinject(op PendingOperation, head *goatrpc.Block, freshBranch bool) error {
+	op.Attempts++
+	op.Fee += t.cfg.FeeBumpMutez
+
+	branch := op.Branch
+	counter := op.Counter
+	if freshBranch {
+		branch = head.Hash
+		newCounter, err := t.client.Counter(op.Source, head.Hash)
+		if err != nil {
+			return errors.Wrap(err, "tracker: failed to fetch counter for re-forge")
+		}
+		counter = newCounter + 1
+	}
+	start := counter
+
+	var contents []goatrpc.Contents
+	for _, p := range op.Payments {
+		contents = append(contents, goatrpc.Contents{
+			Kind:         "transaction",
+			Source:       op.Source,
+			Fee:          strconv.Itoa(op.Fee),
+			GasLimit:     strconv.Itoa(op.Gaslimit),
+			StorageLimit: "0",
+			Amount:       strconv.FormatFloat(p.Amount, 'f', 0, 64),
+			Destination:  p.Address,
+			Counter:      strconv.Itoa(counter),
+		})
+		counter++
+	}
+
+	operationBytes, err := forge.Encode(branch, contents...)
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to re-forge operation")
+	}
+
+	opBytes, err := hex.DecodeString(operationBytes)
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to decode re-forged bytes")
+	}
+
+	edsig, err := t.signer.Sign(payout.WatermarkGenericOperation, opBytes)
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to re-sign operation")
+	}
+
+	decodedSignature, err := payout.DecodeSignature(edsig)
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to decode signature")
+	}
+
+	ophash, err := t.client.InjectionOperation(goatrpc.InjectionOperationInput{
+		Operation: operationBytes + decodedSignature,
+	})
+	if err != nil {
+		return errors.Wrap(err, "tracker: failed to re-inject operation")
+	}
+
+	if err := t.store.Delete(op.Ophash); err != nil {
+		return errors.Wrap(err, "tracker: failed to drop superseded operation")
+	}
+
+	op.Ophash = ophash
+	op.Branch = branch
+	op.Counter = start
+	op.InjectedAtLevel = head.Metadata.Level.Level
+	if err := t.store.Put(op); err != nil {
+		return errors.Wrap(err, "tracker: failed to persist re-injected operation")
+	}
+
+	t.notify(payout.EventKindReinjected, op, "operation re-injected with bumped fee")
+	return nil
+}
+
+func (t *OperationTracker) notify(kind payout.EventKind, op PendingOperation, message string) {
+	if t.notifier == nil {
+		return
+	}
+	if err := t.notifier.Notify(payout.Event{Kind: kind, Ophash: op.Ophash, Message: message}); err != nil {
+		log.WithField("error", err.Error()).Warn("tracker: notifier failed")
+	}
+}
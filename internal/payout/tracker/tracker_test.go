@@ -0,0 +1,116 @@
+package tracker
+
+import (
+	"testing"
+
+	"github.com/goat-systems/tzpay/v2/internal/payout"
+)
+
+// fakeStore is an in-memory Store for exercising maybeConfirmCycle without a
+// real BoltDB file on disk.
+type fakeStore struct {
+	ops []PendingOperation
+}
+
+func (s *fakeStore) Put(op PendingOperation) error {
+	s.ops = append(s.ops, op)
+	return nil
+}
+
+func (s *fakeStore) Delete(ophash string) error {
+	for i, op := range s.ops {
+		if op.Ophash == ophash {
+			s.ops = append(s.ops[:i], s.ops[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) All() ([]PendingOperation, error) {
+	return s.ops, nil
+}
+
+func (s *fakeStore) Close() error {
+	return nil
+}
+
+// fakeQueueStore is an in-memory payout.QueueStore for exercising
+// maybeConfirmCycle's write-back into the queue.
+type fakeQueueStore struct {
+	records []payout.Record
+}
+
+func (s *fakeQueueStore) Put(record payout.Record) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *fakeQueueStore) All() ([]payout.Record, error) {
+	return s.records, nil
+}
+
+func (s *fakeQueueStore) Close() error {
+	return nil
+}
+
+// maybeConfirmCycle must not mark a cycle confirmed while a sibling
+// operation for the same cycle is still pending: confirming early would let
+// Queue.Rehydrate treat an only-partially-confirmed cycle as fully settled.
+func TestMaybeConfirmCycleWaitsForSiblings(t *testing.T) {
+	store := &fakeStore{ops: []PendingOperation{
+		{Ophash: "op2", Cycle: 50},
+	}}
+	queueStore := &fakeQueueStore{}
+
+	tr := New(nil, nil, store, queueStore, nil, Config{})
+
+	if err := tr.maybeConfirmCycle(PendingOperation{Ophash: "op1", Cycle: 50}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(queueStore.records) != 0 {
+		t.Fatalf("expected no queue record to be written while siblings remain pending, got %v", queueStore.records)
+	}
+}
+
+// Once no sibling operation for the cycle remains pending, maybeConfirmCycle
+// must mark the cycle StateConfirmed, carrying over the ophashes already
+// recorded for it.
+func TestMaybeConfirmCycleConfirmsOnLastSibling(t *testing.T) {
+	store := &fakeStore{}
+	queueStore := &fakeQueueStore{records: []payout.Record{
+		{Cycle: 50, State: payout.StateInjected, Ophashes: []string{"op1", "op2"}},
+	}}
+
+	tr := New(nil, nil, store, queueStore, nil, Config{})
+
+	if err := tr.maybeConfirmCycle(PendingOperation{Ophash: "op2", Cycle: 50}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(queueStore.records) != 2 {
+		t.Fatalf("expected a new record to be appended, got %v", queueStore.records)
+	}
+	confirmed := queueStore.records[len(queueStore.records)-1]
+	if confirmed.State != payout.StateConfirmed {
+		t.Fatalf("expected state %q, got %q", payout.StateConfirmed, confirmed.State)
+	}
+	if confirmed.Cycle != 50 {
+		t.Fatalf("expected cycle 50, got %d", confirmed.Cycle)
+	}
+	if len(confirmed.Ophashes) != 2 || confirmed.Ophashes[0] != "op1" || confirmed.Ophashes[1] != "op2" {
+		t.Fatalf("expected the ophashes to carry over from the injected record, got %v", confirmed.Ophashes)
+	}
+}
+
+// Without a queueStore wired up, maybeConfirmCycle has nothing to write to
+// and must be a no-op rather than nil-pointer-dereferencing.
+func TestMaybeConfirmCycleNoQueueStore(t *testing.T) {
+	store := &fakeStore{}
+	tr := New(nil, nil, store, nil, nil, Config{})
+
+	if err := tr.maybeConfirmCycle(PendingOperation{Ophash: "op1", Cycle: 50}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+// Package rpc wraps github.com/goat-systems/go-tezos/v3/rpc behind tzpay's
+// own IFace so the rest of tzpay can talk to many Tezos nodes as if they
+// were one: reads round-robin across whichever endpoints are currently
+// healthy, slow calls are hedged to a second endpoint, and injections are
+// broadcast to every healthy endpoint so a single stuck node can't cause a
+// missed payout cycle.
+package rpc
+
+import (
+	"github.com/goat-systems/go-tezos/v3/rpc"
+)
+
+// IFace is the set of Tezos RPC calls tzpay depends on. It is implemented
+// by *multiClient, which New returns for any number of endpoints, including
+// one; the upstream *rpc.Client has no PendingOperations method and is not
+// an IFace.
+type IFace interface {
+	Head() (*rpc.Block, error)
+	Block(level int) (*rpc.Block, error)
+	Counter(address, blockhash string) (int, error)
+	PreapplyOperations(input rpc.PreapplyOperationsInput) ([]rpc.Operations, error)
+	InjectionOperation(input rpc.InjectionOperationInput) (string, error)
+	// PendingOperations returns the ophashes of the mempool's applied
+	// operations, so the operation tracker can tell an op that's merely slow
+	// to include from one that was dropped outright. Operations in the
+	// mempool's other classifications are not included.
+	PendingOperations() ([]string, error)
+	// RunOperation simulates an unsigned operation against the head context,
+	// returning the consumed gas/storage and any Michelson errors per
+	// content, without requiring a signature or broadcasting anything.
+	RunOperation(input rpc.RunOperationInput) (*rpc.RunOperationResults, error)
+	// Constants returns the head block's protocol constants (minimal fees,
+	// gas costs, etc.) used to estimate fees for a simulated batch.
+	Constants() (*rpc.Constants, error)
+}
@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"sync"
+	"time"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxLevelLag is how many blocks behind the quorum's head level an endpoint
+// may be before it's pulled out of rotation.
+const maxLevelLag = 2
+
+// endpoint tracks one Tezos node's client and the health-checker's current
+// opinion of it.
+type endpoint struct {
+	url    string
+	client *rpc.Client
+
+	mu      sync.RWMutex
+	healthy bool
+	level   int
+}
+
+func newEndpoint(url string) (*endpoint, error) {
+	client, err := rpc.New(url)
+	if err != nil {
+		return nil, err
+	}
+
+	return &endpoint{url: url, client: client, healthy: true}, nil
+}
+
+func (e *endpoint) setStatus(level int, healthy bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.level = level
+	e.healthy = healthy
+}
+
+func (e *endpoint) isHealthy() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.healthy
+}
+
+func (e *endpoint) currentLevel() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.level
+}
+
+// healthChecker polls every endpoint's head header on an interval, and
+// marks any endpoint whose level lags the quorum (the max level seen
+// across all endpoints) by more than maxLevelLag blocks as unhealthy.
+type healthChecker struct {
+	endpoints []*endpoint
+	interval  time.Duration
+	stop      chan struct{}
+}
+
+func newHealthChecker(endpoints []*endpoint, interval time.Duration) *healthChecker {
+	return &healthChecker{endpoints: endpoints, interval: interval, stop: make(chan struct{})}
+}
+
+func (h *healthChecker) start() {
+	h.check()
+
+	ticker := time.NewTicker(h.interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				h.check()
+			case <-h.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (h *healthChecker) close() {
+	close(h.stop)
+}
+
+func (h *healthChecker) check() {
+	var wg sync.WaitGroup
+	levels := make([]int, len(h.endpoints))
+	reachable := make([]bool, len(h.endpoints))
+
+	for i, ep := range h.endpoints {
+		wg.Add(1)
+		go func(i int, ep *endpoint) {
+			defer wg.Done()
+			block, err := ep.client.Head()
+			if err != nil {
+				log.WithFields(log.Fields{"endpoint": ep.url, "error": err.Error()}).Warn("rpc: endpoint health check failed")
+				return
+			}
+			levels[i] = block.Metadata.Level.Level
+			reachable[i] = true
+		}(i, ep)
+	}
+	wg.Wait()
+
+	quorum := 0
+	for i, ok := range reachable {
+		if ok && levels[i] > quorum {
+			quorum = levels[i]
+		}
+	}
+
+	for i, ep := range h.endpoints {
+		healthy := reachable[i] && quorum-levels[i] <= maxLevelLag
+		if healthy != ep.isHealthy() {
+			log.WithFields(log.Fields{"endpoint": ep.url, "healthy": healthy, "level": levels[i], "quorum": quorum}).Info("rpc: endpoint health changed")
+		}
+		ep.setStatus(levels[i], healthy)
+	}
+}
+
+func (h *healthChecker) healthyEndpoints() []*endpoint {
+	var healthy []*endpoint
+	for _, ep := range h.endpoints {
+		if ep.isHealthy() {
+			healthy = append(healthy, ep)
+		}
+	}
+	return healthy
+}
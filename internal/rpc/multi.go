@@ -0,0 +1,346 @@
+package rpc
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/goat-systems/go-tezos/v3/rpc"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHealthCheckInterval = 15 * time.Second
+	defaultHedgeThreshold      = 2 * time.Second
+	defaultMaxRetries          = 3
+	defaultRetryBackoff        = 250 * time.Millisecond
+)
+
+// Option configures a multiClient.
+type Option func(*multiClient)
+
+// WithHealthCheckInterval overrides how often endpoints are polled for their
+// current level. Defaults to 15s.
+func WithHealthCheckInterval(d time.Duration) Option {
+	return func(c *multiClient) { c.healthCheckInterval = d }
+}
+
+// WithHedgeThreshold sets how long a latency-sensitive call waits for its
+// first endpoint before also firing the same call at a second endpoint,
+// racing them and keeping whichever answers first. Defaults to 2s.
+func WithHedgeThreshold(d time.Duration) Option {
+	return func(c *multiClient) { c.hedgeThreshold = d }
+}
+
+// WithMaxRetries sets how many attempts a read makes, cycling through the
+// healthy endpoints, before giving up. Any error from an endpoint counts as
+// a failed attempt, whatever its status code. Defaults to 3.
+func WithMaxRetries(n int) Option {
+	return func(c *multiClient) { c.maxRetries = n }
+}
+
+// multiClient implements IFace over a pool of Tezos RPC endpoints. Reads
+// round-robin across healthy endpoints with retry and backoff; injections
+// fan out to every healthy endpoint and return the first success.
+type multiClient struct {
+	endpoints           []*endpoint
+	healthChecker       *healthChecker
+	healthCheckInterval time.Duration
+	hedgeThreshold      time.Duration
+	maxRetries          int
+
+	mu   sync.Mutex
+	next int
+}
+
+// New builds a multi-endpoint RPC client. At least one endpoint is required;
+// a single endpoint behaves like the upstream client, just with retries.
+func New(urls []string, opts ...Option) (IFace, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("rpc: at least one endpoint is required")
+	}
+
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, url := range urls {
+		ep, err := newEndpoint(url)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rpc: failed to connect to %s", url)
+		}
+		endpoints = append(endpoints, ep)
+	}
+
+	c := &multiClient{
+		endpoints:           endpoints,
+		healthCheckInterval: defaultHealthCheckInterval,
+		hedgeThreshold:      defaultHedgeThreshold,
+		maxRetries:          defaultMaxRetries,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.healthChecker = newHealthChecker(endpoints, c.healthCheckInterval)
+	c.healthChecker.start()
+
+	return c, nil
+}
+
+// pickEndpoints returns the pool's healthy endpoints starting from a
+// round-robin cursor, falling back to the full pool if none are currently
+// marked healthy (better to try a possibly-stale node than to fail outright).
+func (c *multiClient) pickEndpoints() []*endpoint {
+	healthy := c.healthChecker.healthyEndpoints()
+	if len(healthy) == 0 {
+		healthy = c.endpoints
+	}
+
+	c.mu.Lock()
+	start := c.next % len(healthy)
+	c.next++
+	c.mu.Unlock()
+
+	ordered := make([]*endpoint, 0, len(healthy))
+	ordered = append(ordered, healthy[start:]...)
+	ordered = append(ordered, healthy[:start]...)
+	return ordered
+}
+
+// withRetry tries call against successive endpoints (in round-robin order)
+// until one succeeds or the retry budget is exhausted, backing off
+// exponentially between attempts.
+func (c *multiClient) withRetry(call func(*rpc.Client) error) error {
+	ordered := c.pickEndpoints()
+
+	attempts := c.maxRetries
+	if attempts == 0 {
+		attempts = 1
+	}
+
+	return retryN(attempts, defaultRetryBackoff, func(i int) error {
+		ep := ordered[i%len(ordered)]
+		if err := call(ep.client); err != nil {
+			log.WithFields(log.Fields{"endpoint": ep.url, "error": err.Error()}).Warn("rpc: call failed, retrying")
+			return errors.Wrapf(err, "endpoint %s", ep.url)
+		}
+		return nil
+	})
+}
+
+// retryN calls attempt(0), attempt(1), ... up to attempts times, backing off
+// exponentially from backoff between tries, and returns the last error if
+// none of them succeed. Split out of withRetry so the retry/backoff logic
+// can be tested without a real endpoint.
+func retryN(attempts int, backoff time.Duration, attempt func(i int) error) error {
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		if err := attempt(i); err != nil {
+			lastErr = err
+			time.Sleep(backoff)
+			backoff *= 2
+			continue
+		}
+		return nil
+	}
+
+	return lastErr
+}
+
+// hedged races call against the first endpoint; if it hasn't answered by
+// hedgeThreshold, call is also fired at a second endpoint, and whichever
+// finishes first wins.
+func (c *multiClient) hedged(call func(*rpc.Client) (interface{}, error)) (interface{}, error) {
+	ordered := c.pickEndpoints()
+	return hedgedRace(len(ordered), c.hedgeThreshold, func(i int) (interface{}, error) {
+		return call(ordered[i].client)
+	})
+}
+
+// hedgedRace fires attempt(0) and, if it hasn't answered (successfully or
+// not) within threshold, also fires attempt(1 % total), keeping whichever
+// answers successfully first. Split out of hedged so the racing/outstanding
+// bookkeeping can be tested without a real endpoint.
+func hedgedRace(total int, threshold time.Duration, attempt func(i int) (interface{}, error)) (interface{}, error) {
+	if total == 1 {
+		return attempt(0)
+	}
+
+	type result struct {
+		value interface{}
+		err   error
+	}
+
+	results := make(chan result, 2)
+	fire := func(i int) {
+		value, err := attempt(i)
+		results <- result{value, err}
+	}
+
+	go fire(0)
+	outstanding := 1
+	hedgeFired := false
+
+	timer := time.NewTimer(threshold)
+	defer timer.Stop()
+
+	// outstanding tracks how many fires are still in flight, rather than
+	// assuming exactly two results always arrive: if the first attempt
+	// errors before the hedge timer fires, only the hedge itself is ever
+	// launched, so waiting for two results would block forever.
+	var lastErr error
+	for outstanding > 0 {
+		var timerC <-chan time.Time
+		if !hedgeFired {
+			timerC = timer.C
+		}
+
+		select {
+		case res := <-results:
+			outstanding--
+			if res.err == nil {
+				return res.value, nil
+			}
+			lastErr = res.err
+			if !hedgeFired {
+				hedgeFired = true
+				go fire(1 % total)
+				outstanding++
+			}
+		case <-timerC:
+			hedgeFired = true
+			go fire(1 % total)
+			outstanding++
+		}
+	}
+
+	return nil, lastErr
+}
+
+// Head implements IFace, hedging against a second endpoint since it's on
+// the hot path of every payout cycle check.
+func (c *multiClient) Head() (*rpc.Block, error) {
+	value, err := c.hedged(func(client *rpc.Client) (interface{}, error) {
+		return client.Head()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value.(*rpc.Block), nil
+}
+
+// Block implements IFace with plain round-robin retry.
+func (c *multiClient) Block(level int) (*rpc.Block, error) {
+	var block *rpc.Block
+	err := c.withRetry(func(client *rpc.Client) error {
+		var err error
+		block, err = client.Block(level)
+		return err
+	})
+	return block, err
+}
+
+// PendingOperations implements IFace with plain round-robin retry.
+func (c *multiClient) PendingOperations() ([]string, error) {
+	var ophashes []string
+	err := c.withRetry(func(client *rpc.Client) error {
+		applied, err := client.MempoolPendingOperations()
+		if err != nil {
+			return err
+		}
+		ophashes = ophashes[:0]
+		for _, op := range applied.Applied {
+			ophashes = append(ophashes, op.Hash)
+		}
+		return nil
+	})
+	return ophashes, err
+}
+
+// RunOperation implements IFace with plain round-robin retry.
+func (c *multiClient) RunOperation(input rpc.RunOperationInput) (*rpc.RunOperationResults, error) {
+	var results *rpc.RunOperationResults
+	err := c.withRetry(func(client *rpc.Client) error {
+		var err error
+		results, err = client.RunOperation(input)
+		return err
+	})
+	return results, err
+}
+
+// Constants implements IFace with plain round-robin retry.
+func (c *multiClient) Constants() (*rpc.Constants, error) {
+	var constants *rpc.Constants
+	err := c.withRetry(func(client *rpc.Client) error {
+		var err error
+		constants, err = client.Constants()
+		return err
+	})
+	return constants, err
+}
+
+// Counter implements IFace with plain round-robin retry.
+func (c *multiClient) Counter(address, blockhash string) (int, error) {
+	var counter int
+	err := c.withRetry(func(client *rpc.Client) error {
+		var err error
+		counter, err = client.Counter(address, blockhash)
+		return err
+	})
+	return counter, err
+}
+
+// PreapplyOperations implements IFace with plain round-robin retry.
+func (c *multiClient) PreapplyOperations(input rpc.PreapplyOperationsInput) ([]rpc.Operations, error) {
+	var operations []rpc.Operations
+	err := c.withRetry(func(client *rpc.Client) error {
+		var err error
+		operations, err = client.PreapplyOperations(input)
+		return err
+	})
+	return operations, err
+}
+
+// InjectionOperation implements IFace by broadcasting the injection to every
+// currently healthy endpoint in parallel and returning the first ophash any
+// of them accepts, so one slow or forked node can't cause a missed payout.
+func (c *multiClient) InjectionOperation(input rpc.InjectionOperationInput) (string, error) {
+	healthy := c.healthChecker.healthyEndpoints()
+	if len(healthy) == 0 {
+		healthy = c.endpoints
+	}
+
+	type result struct {
+		ophash string
+		err    error
+	}
+
+	results := make(chan result, len(healthy))
+	for _, ep := range healthy {
+		go func(ep *endpoint) {
+			ophash, err := ep.client.InjectionOperation(input)
+			if err != nil {
+				log.WithFields(log.Fields{"endpoint": ep.url, "error": err.Error()}).Warn("rpc: injection failed on endpoint")
+			}
+			results <- result{ophash, err}
+		}(ep)
+	}
+
+	var lastErr error
+	for i := 0; i < len(healthy); i++ {
+		res := <-results
+		if res.err == nil {
+			return res.ophash, nil
+		}
+		lastErr = res.err
+	}
+
+	return "", errors.Wrap(lastErr, "InjectionOperation failed on all healthy endpoints")
+}
+
+func init() {
+	// Seeds math/rand from the clock. Health checks are not jittered: the
+	// first one runs synchronously in New and the rest on a fixed ticker.
+	rand.Seed(time.Now().UnixNano())
+}
@@ -0,0 +1,143 @@
+package rpc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryN(t *testing.T) {
+	t.Run("succeeds on the first attempt", func(t *testing.T) {
+		calls := 0
+		err := retryN(3, time.Millisecond, func(i int) error {
+			calls++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if calls != 1 {
+			t.Fatalf("expected 1 call, got %d", calls)
+		}
+	})
+
+	t.Run("retries until it succeeds", func(t *testing.T) {
+		calls := 0
+		err := retryN(3, time.Millisecond, func(i int) error {
+			calls++
+			if i < 2 {
+				return errors.New("boom")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if calls != 3 {
+			t.Fatalf("expected 3 calls, got %d", calls)
+		}
+	})
+
+	t.Run("returns the last error once the budget is exhausted", func(t *testing.T) {
+		calls := 0
+		err := retryN(3, time.Millisecond, func(i int) error {
+			calls++
+			return errors.New("attempt failed")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if calls != 3 {
+			t.Fatalf("expected 3 calls, got %d", calls)
+		}
+	})
+}
+
+func TestHedgedRace(t *testing.T) {
+	t.Run("single endpoint calls through directly without racing", func(t *testing.T) {
+		value, err := hedgedRace(1, time.Millisecond, func(i int) (interface{}, error) {
+			return "only", nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if value != "only" {
+			t.Fatalf("expected %q, got %v", "only", value)
+		}
+	})
+
+	t.Run("first endpoint answering before the threshold wins without hedging", func(t *testing.T) {
+		fired := make(chan int, 2)
+		value, err := hedgedRace(2, 50*time.Millisecond, func(i int) (interface{}, error) {
+			fired <- i
+			return i, nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if value != 0 {
+			t.Fatalf("expected the first endpoint's result, got %v", value)
+		}
+		if len(fired) != 1 {
+			t.Fatalf("expected only the first endpoint to be called, got %d calls", len(fired))
+		}
+	})
+
+	t.Run("hedges to the second endpoint once the threshold elapses", func(t *testing.T) {
+		value, err := hedgedRace(2, time.Millisecond, func(i int) (interface{}, error) {
+			if i == 0 {
+				time.Sleep(50 * time.Millisecond)
+			}
+			return i, nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if value != 1 {
+			t.Fatalf("expected the hedged endpoint's result, got %v", value)
+		}
+	})
+
+	// Regression test for a deadlock: if the first endpoint errors before
+	// the hedge timer fires, hedgedRace used to still wait for two results
+	// total even though only the hedge attempt was ever launched, blocking
+	// forever. Run on a goroutine with a timeout so a regression fails the
+	// test instead of hanging the suite.
+	t.Run("does not deadlock when the first endpoint errors before the threshold", func(t *testing.T) {
+		done := make(chan struct{})
+		var value interface{}
+		var err error
+
+		go func() {
+			value, err = hedgedRace(2, time.Hour, func(i int) (interface{}, error) {
+				if i == 0 {
+					return nil, errors.New("first endpoint down")
+				}
+				return "second", nil
+			})
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("hedgedRace deadlocked when the first endpoint errored early")
+		}
+
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if value != "second" {
+			t.Fatalf("expected the hedged endpoint's result, got %v", value)
+		}
+	})
+
+	t.Run("returns the last error when every endpoint fails", func(t *testing.T) {
+		_, err := hedgedRace(2, time.Millisecond, func(i int) (interface{}, error) {
+			return nil, errors.New("endpoint down")
+		})
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}